@@ -1,82 +1,307 @@
+// Command capture-node runs browser sessions and records captures. It is
+// configured through the environment:
+//
+//	LISTEN_ADDR, PUBLIC_HOST, VIEWER_URL, EVENT_LOG_URL, TRUSTED_PROXIES
+//	    HTTP service and the links it hands out.
+//	STORAGE_BACKEND ("minio" or "filesystem")
+//	    MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY, MINIO_BUCKET,
+//	    MINIO_USE_SSL, MINIO_PUBLIC_URL for minio; STORAGE_PATH and
+//	    STORAGE_PUBLIC_URL for filesystem.
+//	DATA_DIR
+//	    Node state that must survive restarts. Defaults to STORAGE_PATH,
+//	    or ./data.
+//	SIGNING_KEY_FILE, SIGNING_KEYRING_FILE
+//	    The node's signing key, created on first start, and the public
+//	    keys it has signed with. Default to node_signing_key.pem and
+//	    node_keys.json in DATA_DIR.
+//	POOL_CLAIMS_FILE
+//	    Pooled browsers handed to sessions. Defaults to pool_claims.json
+//	    in DATA_DIR.
+//	TSA_URL, TSA_TIMEOUT, TSA_CA_FILE
+//	    RFC 3161 timestamping of manifests; off without TSA_URL.
+//	BROWSER_RUNTIME ("docker" or "process"), DOCKER_NETWORK,
+//	EGRESS_FIREWALL_IMAGE, BROWSER_AGENT_CMD
+//	    Where browsers run.
+//	BROWSER_PROFILES_FILE, BROWSER_IMAGES, BROWSER_MAX_MEMORY_MB,
+//	BROWSER_MAX_CPUS, MAX_FULL_PAGE_HEIGHT, TLS_PROBE_TIMEOUT
+//	    Browser profiles and capture limits.
+//	EGRESS_PROXY, EGRESS_PROXIES, EGRESS_DENY_CIDRS
+//	    Browser network egress.
+//	SESSION_TIMEOUT, SESSION_IDLE_TIMEOUT, SESSION_MAX_LIFETIME,
+//	SESSION_EXPIRY_WARNING
+//	    Session lifetime.
+//	POOL_SIZE, POOL_MIN_IDLE, POOL_MAX_TOTAL
+//	    Warm browser pool.
+//	MAX_SESSIONS, MAX_SESSIONS_PER_CLIENT, SESSION_QUEUE_SIZE,
+//	SESSION_QUEUE_TIMEOUT, SESSION_RETRY_AFTER
+//	    Session admission.
 package main
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/intraceai/capture-node/internal/api"
 	"github.com/intraceai/capture-node/internal/manifest"
 	"github.com/intraceai/capture-node/internal/orchestrator"
+	"github.com/intraceai/capture-node/internal/signing"
 	"github.com/intraceai/capture-node/internal/storage"
+	"github.com/intraceai/capture-node/internal/timestamp"
+	"github.com/intraceai/capture-node/internal/tlsprobe"
+	"github.com/intraceai/capture-node/pkg/shared"
 )
 
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	minioEndpoint := getEnv("MINIO_ENDPOINT", "localhost:9000")
-	minioAccessKey := getEnv("MINIO_ACCESS_KEY", "intrace")
-	minioSecretKey := getEnv("MINIO_SECRET_KEY", "intrace123")
-	minioBucket := getEnv("MINIO_BUCKET", "intrace")
-	minioUseSSL := getEnv("MINIO_USE_SSL", "false") == "true"
-	minioPublicURL := getEnv("MINIO_PUBLIC_URL", "http://localhost:9000")
-
 	eventLogURL := getEnv("EVENT_LOG_URL", "http://localhost:8081")
 	publicHost := getEnv("PUBLIC_HOST", "localhost")
 	viewerURL := getEnv("VIEWER_URL", "http://localhost:3000")
-	dockerNetwork := getEnv("DOCKER_NETWORK", "")
 	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	// Node state shares the storage volume unless given one of its own.
+	dataDir := getEnv("DATA_DIR", getEnv("STORAGE_PATH", "./data"))
 
-	store, err := storage.NewMinIOStorage(
-		minioEndpoint,
-		minioAccessKey,
-		minioSecretKey,
-		minioBucket,
-		minioUseSSL,
-		minioPublicURL,
-	)
+	store, err := newStorage(ctx, getEnv("STORAGE_BACKEND", "minio"))
 	if err != nil {
 		log.Fatalf("failed to create storage: %v", err)
 	}
 
-	if err := store.EnsureBucket(ctx); err != nil {
-		log.Fatalf("failed to ensure bucket: %v", err)
+	profiles, err := loadProfiles(getEnv("BROWSER_PROFILES_FILE", ""))
+	if err != nil {
+		log.Fatalf("failed to load browser profiles: %v", err)
 	}
 
-	orch, err := orchestrator.NewOrchestrator(dockerNetwork)
+	egress := orchestrator.EgressConfig{
+		DefaultProxy: getEnv("EGRESS_PROXY", ""),
+		Proxies:      getEnvList("EGRESS_PROXIES"),
+	}
+	// Unset keeps the default deny list; set but empty turns it off.
+	if _, ok := os.LookupEnv("EGRESS_DENY_CIDRS"); ok {
+		egress.DenyCIDRs = append([]string{}, getEnvList("EGRESS_DENY_CIDRS")...)
+	}
+	if err := egress.Validate(); err != nil {
+		log.Fatalf("invalid egress config: %v", err)
+	}
+
+	runtime, err := newRuntime(getEnv("BROWSER_RUNTIME", "docker"))
 	if err != nil {
-		log.Fatalf("failed to create orchestrator: %v", err)
+		log.Fatalf("failed to create browser runtime: %v", err)
 	}
-	orch.Start(ctx)
-	defer orch.Stop()
 
+	orch := orchestrator.NewOrchestrator(runtime, orchestrator.Config{
+		Session: orchestrator.SessionConfig{
+			Timeout:       getEnvDuration("SESSION_TIMEOUT", 15*time.Minute),
+			IdleTimeout:   getEnvDuration("SESSION_IDLE_TIMEOUT", 0),
+			MaxLifetime:   getEnvDuration("SESSION_MAX_LIFETIME", 2*time.Hour),
+			ExpiryWarning: getEnvDuration("SESSION_EXPIRY_WARNING", time.Minute),
+		},
+		Pool: orchestrator.PoolConfig{
+			Size:     getEnvInt("POOL_SIZE", 0),
+			MinIdle:  getEnvInt("POOL_MIN_IDLE", 0),
+			MaxTotal: getEnvInt("POOL_MAX_TOTAL", 0),
+			// Must persist with the browsers for sessions to survive a
+			// restart of the node.
+			ClaimsFile: getEnv("POOL_CLAIMS_FILE", filepath.Join(dataDir, "pool_claims.json")),
+		},
+		Limits: orchestrator.LimitsConfig{
+			MaxSessions:  getEnvInt("MAX_SESSIONS", 0),
+			MaxPerClient: getEnvInt("MAX_SESSIONS_PER_CLIENT", 0),
+			QueueSize:    getEnvInt("SESSION_QUEUE_SIZE", 0),
+			QueueTimeout: getEnvDuration("SESSION_QUEUE_TIMEOUT", 30*time.Second),
+			RetryAfter:   getEnvDuration("SESSION_RETRY_AFTER", 30*time.Second),
+		},
+		Profiles: orchestrator.ProfileConfig{
+			Profiles:    profiles,
+			Images:      getEnvList("BROWSER_IMAGES"),
+			MaxMemoryMB: getEnvInt("BROWSER_MAX_MEMORY_MB", 4096),
+			MaxCPUs:     getEnvFloat("BROWSER_MAX_CPUS", 4),
+		},
+		Egress: egress,
+		Capture: orchestrator.CaptureConfig{
+			MaxFullPageHeight: getEnvInt("MAX_FULL_PAGE_HEIGHT", 16384),
+		},
+	})
 	manifestBuilder := manifest.NewBuilder()
 
+	tlsProber := tlsprobe.NewProber()
+	tlsProber.Timeout = getEnvDuration("TLS_PROBE_TIMEOUT", tlsProber.Timeout)
+
+	// The signing key and keyring must outlive the container: a lost key
+	// means a new identity, and a lost keyring leaves earlier signatures
+	// without a published key. Both default into dataDir.
+	signingKeyFile := getEnv("SIGNING_KEY_FILE", filepath.Join(dataDir, "node_signing_key.pem"))
+	signer, created, err := signing.LoadOrCreate(signingKeyFile)
+	if err != nil {
+		log.Fatalf("failed to load signing key: %v", err)
+	}
+	if created {
+		log.Printf("generated signing key %s at %s", signer.KeyID(), signingKeyFile)
+	} else {
+		log.Printf("loaded signing key %s", signer.KeyID())
+	}
+	keyringFile := getEnv("SIGNING_KEYRING_FILE", filepath.Join(filepath.Dir(signingKeyFile), "node_keys.json"))
+	nodeKeys, err := signing.LoadKeyring(keyringFile, signer)
+	if err != nil {
+		log.Fatalf("failed to load signing keyring: %v", err)
+	}
+
+	var timestamper *timestamp.Client
+	if tsaURL := getEnv("TSA_URL", ""); tsaURL != "" {
+		timestamper = timestamp.NewClient(tsaURL)
+		timestamper.Timeout = getEnvDuration("TSA_TIMEOUT", timestamper.Timeout)
+		if caFile := getEnv("TSA_CA_FILE", ""); caFile != "" {
+			pemData, err := os.ReadFile(caFile)
+			if err != nil {
+				log.Fatalf("failed to read TSA_CA_FILE: %v", err)
+			}
+			timestamper.Roots = x509.NewCertPool()
+			if !timestamper.Roots.AppendCertsFromPEM(pemData) {
+				log.Fatalf("TSA_CA_FILE contains no certificates")
+			}
+		}
+	}
+
+	trustedProxies, err := parsePrefixes(getEnvList("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	server := api.NewServer(api.ServerConfig{
-		Storage:      store,
-		Orchestrator: orch,
-		Manifest:     manifestBuilder,
-		EventLogURL:  eventLogURL,
-		PublicHost:   publicHost,
-		ViewerURL:    viewerURL,
+		Storage:        store,
+		Orchestrator:   orch,
+		Manifest:       manifestBuilder,
+		TLSProber:      tlsProber,
+		Signer:         signer,
+		NodeKeys:       nodeKeys,
+		Timestamper:    timestamper,
+		TrustedProxies: trustedProxies,
+		EventLogURL:    eventLogURL,
+		PublicHost:     publicHost,
+		ViewerURL:      viewerURL,
 	})
 
+	// Started last so a fatal configuration error cannot leave pooled
+	// browsers behind.
+	orch.Start(ctx)
+	defer orch.Stop()
+
+	httpServer := &http.Server{Addr: listenAddr, Handler: server.Handler()}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		<-sigChan
 		log.Println("shutting down...")
 		cancel()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer shutdownCancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("server shutdown: %v", err)
+		}
 	}()
 
 	log.Printf("starting capture-node server on %s", listenAddr)
-	if err := server.Run(listenAddr); err != nil {
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("server error: %v", err)
 	}
+
+	// Returning runs the deferred orch.Stop, which stops pooled browsers.
+	<-shutdownDone
+}
+
+func newStorage(ctx context.Context, backend string) (storage.Backend, error) {
+	switch backend {
+	case "minio":
+		minioEndpoint := getEnv("MINIO_ENDPOINT", "localhost:9000")
+		minioAccessKey := getEnv("MINIO_ACCESS_KEY", "intrace")
+		minioSecretKey := getEnv("MINIO_SECRET_KEY", "intrace123")
+		minioBucket := getEnv("MINIO_BUCKET", "intrace")
+		minioUseSSL := getEnv("MINIO_USE_SSL", "false") == "true"
+		minioPublicURL := getEnv("MINIO_PUBLIC_URL", "http://localhost:9000")
+
+		store, err := storage.NewMinIOStorage(
+			minioEndpoint,
+			minioAccessKey,
+			minioSecretKey,
+			minioBucket,
+			minioUseSSL,
+			minioPublicURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := store.EnsureBucket(ctx); err != nil {
+			return nil, fmt.Errorf("failed to ensure bucket: %w", err)
+		}
+		return store, nil
+
+	case "filesystem":
+		storagePath := getEnv("STORAGE_PATH", "./data")
+		storagePublicURL := getEnv("STORAGE_PUBLIC_URL", "http://localhost:8080")
+		return storage.NewFilesystemStorage(storagePath, storagePublicURL)
+
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+func newRuntime(kind string) (orchestrator.Runtime, error) {
+	switch kind {
+	case "docker":
+		dockerNetwork := getEnv("DOCKER_NETWORK", "")
+		return orchestrator.NewDockerRuntime(dockerNetwork, getEnv("EGRESS_FIREWALL_IMAGE", ""))
+
+	case "process":
+		agentCmd := strings.Fields(getEnv("BROWSER_AGENT_CMD", ""))
+		if len(agentCmd) == 0 {
+			return nil, fmt.Errorf("BROWSER_AGENT_CMD is required for the process runtime")
+		}
+		return orchestrator.NewProcessRuntime(agentCmd[0], agentCmd[1:]...), nil
+
+	default:
+		return nil, fmt.Errorf("unknown browser runtime %q", kind)
+	}
+}
+
+// loadProfiles reads named browser profiles from a JSON object keyed by
+// profile name. An empty path yields only the built-in default.
+func loadProfiles(path string) (map[string]shared.BrowserProfile, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles map[string]shared.BrowserProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return profiles, nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -85,3 +310,69 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return n
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return d
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return f
+}
+
+// parsePrefixes accepts CIDR prefixes and bare addresses.
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range values {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -3,10 +3,13 @@ package api
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -14,6 +17,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/intraceai/capture-node/internal/manifest"
+	"github.com/intraceai/capture-node/internal/timestamp"
 	"github.com/intraceai/capture-node/pkg/models"
 	"github.com/intraceai/capture-node/pkg/shared"
 )
@@ -29,35 +33,175 @@ func (s *Server) captureSession(c *gin.Context) {
 		return
 	}
 
-	captureResp, err := s.orchestrator.Capture(c.Request.Context(), sessionID)
+	var opts shared.CaptureOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := shared.NormalizeCaptureOptions(&opts); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	captureReq := shared.BrowserCaptureRequest{
+		FullPage:          opts.Has(shared.ArtifactFullPage),
+		MaxFullPageHeight: opts.MaxFullPageHeight,
+		Wait:              opts.Wait,
+		ClipSelector:      opts.ClipSelector,
+		ImageFormat:       opts.ImageFormat,
+		ImageQuality:      opts.ImageQuality,
+		PDF:               opts.Has(shared.ArtifactPDF),
+		MHTML:             opts.Has(shared.ArtifactMHTML),
+		Exchanges:         opts.Has(shared.ArtifactWARC),
+		HAR:               opts.Has(shared.ArtifactHAR),
+		Cookies:           opts.Has(shared.ArtifactCookies),
+	}
+
+	captureResp, err := s.orchestrator.Capture(c.Request.Context(), sessionID, captureReq)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
+	captureID := uuid.New().String()
+	capturedAt := time.Now().UTC()
+
+	var artifacts []manifest.ArtifactData
+	addArtifact := func(name, mediaType string, data []byte) {
+		artifacts = append(artifacts, manifest.ArtifactData{Name: name, MediaType: mediaType, Data: data})
+	}
+
 	screenshotData, err := base64.StdEncoding.DecodeString(captureResp.Screenshot)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to decode screenshot"})
 		return
 	}
+	addArtifact(shared.ScreenshotFile(opts.ImageFormat), shared.ImageMediaType(opts.ImageFormat), screenshotData)
+	addArtifact(shared.DOMFile, shared.MediaTypeHTML, []byte(captureResp.DOM))
+
+	var fullPage *shared.FullPage
+	if captureReq.FullPage {
+		if captureResp.FullPageScreenshot == "" {
+			c.JSON(500, gin.H{"error": "browser did not return a full-page screenshot"})
+			return
+		}
+		fullPageData, err := base64.StdEncoding.DecodeString(captureResp.FullPageScreenshot)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "failed to decode full-page screenshot"})
+			return
+		}
+		addArtifact(shared.FullPageFile(opts.ImageFormat), shared.ImageMediaType(opts.ImageFormat), fullPageData)
+		fullPage = &shared.FullPage{
+			Height:    captureResp.FullPageHeight,
+			Truncated: captureResp.FullPageTruncated,
+		}
+	}
 
-	domData := []byte(captureResp.DOM)
-	captureID := uuid.New().String()
-	capturedAt := time.Now().UTC()
+	if captureReq.PDF {
+		if captureResp.PDF == "" {
+			c.JSON(500, gin.H{"error": "browser did not return a PDF"})
+			return
+		}
+		pdfData, err := base64.StdEncoding.DecodeString(captureResp.PDF)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "failed to decode PDF"})
+			return
+		}
+		addArtifact(shared.PDFFile, shared.MediaTypePDF, pdfData)
+	}
+
+	if captureReq.MHTML {
+		if captureResp.MHTML == "" {
+			c.JSON(500, gin.H{"error": "browser did not return an MHTML snapshot"})
+			return
+		}
+		addArtifact(shared.MHTMLFile, shared.MediaTypeMHTML, []byte(captureResp.MHTML))
+	}
+
+	if captureReq.Exchanges {
+		if len(captureResp.Exchanges) == 0 {
+			c.JSON(500, gin.H{"error": "browser did not return any HTTP exchanges"})
+			return
+		}
+		exchanges := shared.RedactExchanges(captureResp.Exchanges, opts.RedactCookies)
+		warcData, warcIndex, err := buildWARC(captureID, capturedAt, exchanges)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "failed to build WARC"})
+			return
+		}
+		addArtifact(shared.WARCFile, shared.MediaTypeGzip, warcData)
+		addArtifact(shared.WARCIndexFile, shared.MediaTypeCDXJ, warcIndex)
+	}
+
+	if captureReq.HAR {
+		if !json.Valid([]byte(captureResp.HAR)) {
+			c.JSON(500, gin.H{"error": "browser did not return a valid HAR"})
+			return
+		}
+		harData, err := shared.RedactHAR([]byte(captureResp.HAR), opts.RedactCookies)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "failed to redact HAR"})
+			return
+		}
+		addArtifact(shared.HARFile, shared.MediaTypeJSON, harData)
+	}
+
+	tlsData, err := s.probeServer(c.Request.Context(), sessionID, captureResp.FinalURL)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to record server identity"})
+		return
+	}
+	if tlsData != nil {
+		addArtifact(shared.TLSFile, shared.MediaTypeJSON, tlsData)
+	}
+
+	if captureResp.Response != nil {
+		response := *captureResp.Response
+		response.Headers = shared.RedactSetCookie(response.Headers, opts.RedactCookies)
+		responseData, _ := json.MarshalIndent(response, "", "  ")
+		addArtifact(shared.ResponseFile, shared.MediaTypeJSON, responseData)
+	}
+
+	if captureReq.Cookies {
+		cookies := shared.RedactCookies(captureResp.Cookies, opts.RedactCookies)
+		cookiesData, _ := json.MarshalIndent(cookies, "", "  ")
+		addArtifact(shared.CookiesFile, shared.MediaTypeJSON, cookiesData)
+	}
+
+	console := captureResp.Console
+	if console == nil {
+		console = []shared.ConsoleEntry{}
+	}
+	consoleData, _ := json.MarshalIndent(console, "", "  ")
+	addArtifact(shared.ConsoleFile, shared.MediaTypeJSON, consoleData)
+
+	browserVersion := extractBrowserVersion(captureResp, session.Profile)
 
-	browserVersion := extractBrowserVersion(captureResp.UserAgent)
+	// The manifest URL is what the user asked for, as long as the captured
+	// page was reached from it.
+	var requestedURL string
+	if snapshot, ok := s.orchestrator.SessionSnapshot(sessionID); ok {
+		requestedURL = snapshot.RequestedURL
+	}
+	requestedURL = shared.PageURL(requestedURL, captureResp.FinalURL, captureResp.Redirects)
 
 	buildOutput, err := s.manifest.Build(manifest.BuildInput{
 		CaptureID:      captureID,
-		URL:            captureResp.FinalURL,
+		URL:            requestedURL,
 		FinalURL:       captureResp.FinalURL,
+		Redirects:      captureResp.Redirects,
 		CapturedAtUTC:  capturedAt,
 		BrowserName:    "chromium",
 		BrowserVersion: browserVersion,
+		UserAgent:      captureResp.UserAgent,
 		ViewportWidth:  captureResp.Viewport.Width,
 		ViewportHeight: captureResp.Viewport.Height,
-		ScreenshotData: screenshotData,
-		DOMData:        domData,
+		Profile:        session.Profile,
+		FullPage:       fullPage,
+		CaptureOptions: &opts,
+		Artifacts:      artifacts,
 	})
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to build manifest"})
@@ -66,26 +210,45 @@ func (s *Server) captureSession(c *gin.Context) {
 
 	ctx := c.Request.Context()
 
-	if err := s.storage.StoreScreenshot(ctx, captureID, screenshotData); err != nil {
-		c.JSON(500, gin.H{"error": "failed to store screenshot"})
-		return
+	for _, artifact := range artifacts {
+		if err := s.storage.StoreArtifact(ctx, captureID, artifact.Name, artifact.Data, artifact.MediaType); err != nil {
+			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to store %s", artifact.Name)})
+			return
+		}
 	}
 
-	if err := s.storage.StoreDOM(ctx, captureID, domData); err != nil {
-		c.JSON(500, gin.H{"error": "failed to store DOM"})
+	if err := s.storage.StoreManifest(ctx, captureID, buildOutput.Canonical); err != nil {
+		c.JSON(500, gin.H{"error": "failed to store manifest"})
 		return
 	}
 
-	if err := s.storage.StoreManifest(ctx, captureID, buildOutput.Manifest); err != nil {
-		c.JSON(500, gin.H{"error": "failed to store manifest"})
-		return
+	// The node signs before the event is emitted so captures carry
+	// provenance of their own.
+	if s.signer != nil {
+		signature := s.signer.SignManifest(buildOutput.Canonical)
+		signatureData, _ := json.MarshalIndent(signature, "", "  ")
+		if err := s.storage.StoreArtifact(ctx, captureID, shared.SignatureFile, signatureData, shared.MediaTypeJSON); err != nil {
+			c.JSON(500, gin.H{"error": "failed to store manifest signature"})
+			return
+		}
 	}
 
-	event, err := s.emitEvent(c, captureID, captureResp.FinalURL, capturedAt, shared.Hashes{
-		ManifestSHA256:   buildOutput.ManifestHash,
-		ScreenshotSHA256: buildOutput.ScreenshotHash,
-		DOMSHA256:        buildOutput.DOMHash,
-	})
+	// A TSA outage leaves the capture without a trusted timestamp rather
+	// than failing it.
+	if s.timestamper != nil {
+		token, err := s.timestamper.Timestamp(ctx, buildOutput.ManifestHash)
+		if err != nil {
+			log.Printf("capture %s: timestamp failed: %v", captureID, err)
+		} else if err := s.storage.StoreArtifact(ctx, captureID, shared.TimestampFile, token.DER, shared.MediaTypeTimestampToken); err != nil {
+			c.JSON(500, gin.H{"error": "failed to store timestamp token"})
+			return
+		}
+	}
+
+	hashes := shared.HashesFromArtifacts(buildOutput.ManifestHash, buildOutput.Manifest.Artifacts)
+	hashes.MerkleRoot = buildOutput.Manifest.MerkleRoot
+
+	event, err := s.emitEvent(c, captureID, captureResp.FinalURL, capturedAt, hashes)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to emit event"})
 		return
@@ -103,7 +266,6 @@ func (s *Server) captureSession(c *gin.Context) {
 		ViewURL:   viewURL,
 	}
 
-	_ = session
 	c.JSON(201, resp)
 }
 
@@ -161,13 +323,21 @@ func (s *Server) getCaptureMetadata(c *gin.Context) {
 		CaptureID:     manifest.CaptureID,
 		URL:           manifest.URL,
 		FinalURL:      manifest.FinalURL,
+		Redirects:     manifest.Redirects,
 		CapturedAtUTC: manifest.CapturedAtUTC,
 		Browser:       manifest.Browser,
 		Viewport:      manifest.Viewport,
-		Hashes: shared.Hashes{
-			ScreenshotSHA256: manifest.Hashes.ScreenshotSHA256,
-			DOMSHA256:        manifest.Hashes.DOMSHA256,
-		},
+		Device:        manifest.Device,
+		Hashes:        shared.HashesFromArtifacts("", manifest.Artifacts),
+		Artifacts:     manifest.Artifacts,
+	}
+
+	resp.Hashes.MerkleRoot = manifest.MerkleRoot
+
+	if tokenData, err := s.storage.GetArtifact(c.Request.Context(), captureID, shared.TimestampFile); err == nil {
+		if token, err := timestamp.ParseToken(tokenData); err == nil {
+			resp.Timestamp = token.Summary()
+		}
 	}
 
 	if event != nil {
@@ -179,69 +349,138 @@ func (s *Server) getCaptureMetadata(c *gin.Context) {
 }
 
 func (s *Server) getScreenshot(c *gin.Context) {
-	captureID := c.Param("id")
+	s.serveArtifact(c, func(m *shared.Manifest) string {
+		return shared.ScreenshotFile(imageFormat(m))
+	}, "screenshot not found")
+}
 
-	data, err := s.storage.GetScreenshot(c.Request.Context(), captureID)
-	if err != nil {
-		c.JSON(404, gin.H{"error": "screenshot not found"})
-		return
-	}
+func (s *Server) getFullPageScreenshot(c *gin.Context) {
+	s.serveArtifact(c, func(m *shared.Manifest) string {
+		return shared.FullPageFile(imageFormat(m))
+	}, "full-page screenshot not found")
+}
+
+func (s *Server) getPDF(c *gin.Context) {
+	s.serveArtifact(c, artifactNamed(shared.PDFFile), "PDF not found")
+}
+
+func (s *Server) getMHTML(c *gin.Context) {
+	captureID := c.Param("id")
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=capture-%s.mhtml", captureID[:min(8, len(captureID))]))
+	s.serveArtifact(c, artifactNamed(shared.MHTMLFile), "MHTML snapshot not found")
+}
 
-	c.Data(200, "image/png", data)
+func (s *Server) getConsole(c *gin.Context) {
+	s.serveArtifact(c, artifactNamed(shared.ConsoleFile), "console log not found")
 }
 
 func (s *Server) getDOM(c *gin.Context) {
+	s.serveArtifact(c, artifactNamed(shared.DOMFile), "DOM not found")
+}
+
+func (s *Server) getArtifact(c *gin.Context) {
+	s.serveArtifact(c, artifactNamed(c.Param("name")), "artifact not found")
+}
+
+// getProof returns the Merkle inclusion proof for one artifact, so it can be
+// shown to belong to the anchored capture without disclosing the others.
+func (s *Server) getProof(c *gin.Context) {
 	captureID := c.Param("id")
 
-	data, err := s.storage.GetDOM(c.Request.Context(), captureID)
+	manifest, err := s.storage.GetManifest(c.Request.Context(), captureID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "capture not found"})
+		return
+	}
+	if manifest.MerkleRoot == "" {
+		c.JSON(404, gin.H{"error": "capture has no Merkle root"})
+		return
+	}
+	if manifest.MerkleAlgorithm != "" && manifest.MerkleAlgorithm != shared.MerkleAlgorithm {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("unsupported Merkle algorithm %q", manifest.MerkleAlgorithm)})
+		return
+	}
+
+	proof, err := shared.NewMerkleProof(manifest.Artifacts, c.Param("artifact"))
 	if err != nil {
-		c.JSON(404, gin.H{"error": "DOM not found"})
+		c.JSON(404, gin.H{"error": "artifact not found"})
+		return
+	}
+	if proof.Root != manifest.MerkleRoot {
+		c.JSON(500, gin.H{"error": "artifacts do not match the manifest Merkle root"})
 		return
 	}
 
-	c.Data(200, "text/html; charset=utf-8", data)
+	c.JSON(200, proof)
 }
 
+// getManifest serves the stored manifest bytes unchanged. They are the
+// canonical encoding the manifest hash covers; manifests stored before that
+// were indented and are hashed as shared.CanonicalManifest(data).
 func (s *Server) getManifest(c *gin.Context) {
 	captureID := c.Param("id")
 
-	manifest, err := s.storage.GetManifest(c.Request.Context(), captureID)
+	data, err := s.storage.GetArtifact(c.Request.Context(), captureID, shared.ManifestFile)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "manifest not found"})
 		return
 	}
 
-	c.JSON(200, manifest)
+	c.Data(200, shared.MediaTypeJSON, data)
 }
 
 func (s *Server) getBundle(c *gin.Context) {
 	captureID := c.Param("id")
 	ctx := c.Request.Context()
 
-	screenshot, err := s.storage.GetScreenshot(ctx, captureID)
+	manifestData, err := s.storage.GetManifest(ctx, captureID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "capture not found"})
 		return
 	}
 
-	dom, _ := s.storage.GetDOM(ctx, captureID)
-	manifestData, _ := s.storage.GetManifest(ctx, captureID)
-	event, _ := s.storage.GetEvent(ctx, captureID)
-
 	var buf bytes.Buffer
 	zipWriter := zip.NewWriter(&buf)
 
-	addFile(zipWriter, "screenshot.png", screenshot)
-	if dom != nil {
-		addFile(zipWriter, "dom.html", dom)
-	}
-	if manifestData != nil {
-		manifestJSON, _ := json.MarshalIndent(manifestData, "", "  ")
-		addFile(zipWriter, "manifest.json", manifestJSON)
+	for _, artifact := range manifestData.Artifacts {
+		data, err := s.storage.GetArtifact(ctx, captureID, artifact.Name)
+		if err != nil {
+			log.Printf("bundle %s: missing artifact %s: %v", captureID, artifact.Name, err)
+			continue
+		}
+		addFile(zipWriter, artifact.Name, data)
 	}
+
+	manifestJSON, _ := s.storage.GetArtifact(ctx, captureID, shared.ManifestFile)
+	addFile(zipWriter, shared.ManifestFile, manifestJSON)
+
+	event, _ := s.storage.GetEvent(ctx, captureID)
 	if event != nil {
 		eventJSON, _ := json.MarshalIndent(event, "", "  ")
-		addFile(zipWriter, "event.json", eventJSON)
+		addFile(zipWriter, shared.EventFile, eventJSON)
+	}
+
+	if token, err := s.storage.GetArtifact(ctx, captureID, shared.TimestampFile); err == nil {
+		addFile(zipWriter, shared.TimestampFile, token)
+	}
+
+	if signature, err := s.storage.GetArtifact(ctx, captureID, shared.SignatureFile); err == nil {
+		var sig shared.ManifestSignature
+		if err := json.Unmarshal(signature, &sig); err != nil {
+			c.JSON(500, gin.H{"error": "stored manifest signature is invalid"})
+			return
+		}
+		// The bundle carries the key that made the signature, which may
+		// have been retired since.
+		key, ok := s.nodeKeys.Find(sig.KeyID)
+		if !ok {
+			log.Printf("bundle %s: manifest signed with unknown key %s", captureID, sig.KeyID)
+			c.JSON(500, gin.H{"error": fmt.Sprintf("manifest signing key %s is not in the node keyring", sig.KeyID)})
+			return
+		}
+		addFile(zipWriter, shared.SignatureFile, signature)
+		nodeKeys, _ := json.MarshalIndent(shared.NodeKeys{Keys: []shared.NodeKey{key}}, "", "  ")
+		addFile(zipWriter, "node_keys.json", nodeKeys)
 	}
 
 	keysResp, err := http.Get(fmt.Sprintf("%s/keys", s.eventLogURL))
@@ -261,6 +500,69 @@ func (s *Server) getBundle(c *gin.Context) {
 	c.Data(200, "application/zip", buf.Bytes())
 }
 
+// serveArtifact writes the artifact picked by name from the capture's
+// manifest, with the media type recorded there.
+func (s *Server) serveArtifact(c *gin.Context, name func(*shared.Manifest) string, notFound string) {
+	captureID := c.Param("id")
+	ctx := c.Request.Context()
+
+	manifest, err := s.storage.GetManifest(ctx, captureID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "capture not found"})
+		return
+	}
+
+	artifact, ok := manifest.Artifact(name(manifest))
+	if !ok {
+		c.JSON(404, gin.H{"error": notFound})
+		return
+	}
+
+	data, err := s.storage.GetArtifact(ctx, captureID, artifact.Name)
+	if err != nil {
+		c.JSON(404, gin.H{"error": notFound})
+		return
+	}
+
+	c.Data(200, artifact.MediaType, data)
+}
+
+func artifactNamed(name string) func(*shared.Manifest) string {
+	return func(*shared.Manifest) string {
+		return name
+	}
+}
+
+// probeServer records the identity of the server behind finalURL under the
+// session's egress policy. URLs without a network host, such as about:blank,
+// produce no artifact.
+func (s *Server) probeServer(ctx context.Context, sessionID, finalURL string) ([]byte, error) {
+	parsed, err := url.Parse(finalURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, nil
+	}
+
+	policy, ok := s.orchestrator.SessionEgress(sessionID)
+	if !ok {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	identity, err := s.tlsProber.Probe(ctx, finalURL, policy)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(identity, "", "  ")
+}
+
+// imageFormat reports the screenshot format recorded in the manifest.
+// Captures predating capture options are PNG.
+func imageFormat(m *shared.Manifest) string {
+	if m.CaptureOptions == nil || m.CaptureOptions.ImageFormat == "" {
+		return shared.ImageFormatPNG
+	}
+	return m.CaptureOptions.ImageFormat
+}
+
 func addFile(zw *zip.Writer, name string, data []byte) {
 	if data == nil {
 		return
@@ -272,13 +574,19 @@ func addFile(zw *zip.Writer, name string, data []byte) {
 	w.Write(data)
 }
 
-func extractBrowserVersion(userAgent string) string {
-	matches := browserVersionRegex.FindStringSubmatch(userAgent)
-	if len(matches) > 1 {
-		return matches[1]
+// extractBrowserVersion takes the version from the product the browser
+// reports. Agents that do not report it fall back to the user agent, which
+// is only trustworthy when the profile does not override it.
+func extractBrowserVersion(resp *shared.BrowserCaptureResponse, profile *shared.BrowserProfile) string {
+	if _, version, ok := strings.Cut(resp.Product, "/"); ok && version != "" {
+		return version
 	}
-	if strings.Contains(userAgent, "Chrome") {
+	if profile != nil && profile.UserAgent != "" {
 		return "unknown"
 	}
+	matches := browserVersionRegex.FindStringSubmatch(resp.UserAgent)
+	if len(matches) > 1 {
+		return matches[1]
+	}
 	return "unknown"
 }
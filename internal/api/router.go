@@ -1,42 +1,80 @@
 package api
 
 import (
+	"net/http"
+	"net/netip"
+
 	"github.com/gin-gonic/gin"
 	"github.com/intraceai/capture-node/internal/manifest"
 	"github.com/intraceai/capture-node/internal/orchestrator"
+	"github.com/intraceai/capture-node/internal/signing"
 	"github.com/intraceai/capture-node/internal/storage"
+	"github.com/intraceai/capture-node/internal/timestamp"
+	"github.com/intraceai/capture-node/internal/tlsprobe"
+	"github.com/intraceai/capture-node/pkg/shared"
 )
 
 type Server struct {
 	router       *gin.Engine
-	storage      *storage.MinIOStorage
+	storage      storage.Backend
 	orchestrator *orchestrator.Orchestrator
 	manifest     *manifest.Builder
+	tlsProber    *tlsprobe.Prober
+	signer       *signing.Signer
+	nodeKeys     shared.NodeKeys
+	timestamper  *timestamp.Client
+	proxies      []netip.Prefix
 	eventLogURL  string
 	publicHost   string
 	viewerURL    string
 }
 
 type ServerConfig struct {
-	Storage      *storage.MinIOStorage
+	Storage      storage.Backend
 	Orchestrator *orchestrator.Orchestrator
 	Manifest     *manifest.Builder
-	EventLogURL  string
-	PublicHost   string
-	ViewerURL    string
+	TLSProber    *tlsprobe.Prober
+	Signer       *signing.Signer
+	// NodeKeys are the node's current and retired verification keys, as
+	// published on /keys. They must include Signer's key.
+	NodeKeys    shared.NodeKeys
+	Timestamper *timestamp.Client
+	// TrustedProxies may set X-Forwarded-For and X-Client-ID. Requests from
+	// anywhere else are identified by their remote address.
+	TrustedProxies []netip.Prefix
+	EventLogURL    string
+	PublicHost     string
+	ViewerURL      string
 }
 
 func NewServer(cfg ServerConfig) *Server {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.Use(gin.Recovery())
+
+	proxies := make([]string, len(cfg.TrustedProxies))
+	for i, prefix := range cfg.TrustedProxies {
+		proxies[i] = prefix.String()
+	}
+	// Valid prefixes cannot fail; an empty list trusts no proxy.
+	router.SetTrustedProxies(proxies)
+
 	router.Use(corsMiddleware())
 
+	if cfg.TLSProber == nil {
+		cfg.TLSProber = tlsprobe.NewProber()
+	}
+
 	s := &Server{
 		router:       router,
 		storage:      cfg.Storage,
 		orchestrator: cfg.Orchestrator,
 		manifest:     cfg.Manifest,
+		tlsProber:    cfg.TLSProber,
+		signer:       cfg.Signer,
+		nodeKeys:     cfg.NodeKeys,
+		timestamper:  cfg.Timestamper,
+		proxies:      cfg.TrustedProxies,
 		eventLogURL:  cfg.EventLogURL,
 		publicHost:   cfg.PublicHost,
 		viewerURL:    cfg.ViewerURL,
@@ -48,11 +86,17 @@ func NewServer(cfg ServerConfig) *Server {
 
 func (s *Server) setupRoutes() {
 	s.router.GET("/health", s.healthCheck)
+	s.router.GET("/status", s.status)
+	s.router.GET("/devices", s.listDevices)
+	s.router.GET("/keys", s.getKeys)
 
 	sessions := s.router.Group("/sessions")
 	{
 		sessions.POST("", s.createSession)
+		sessions.GET("", s.listSessions)
+		sessions.GET("/:id", s.getSession)
 		sessions.DELETE("/:id", s.deleteSession)
+		sessions.POST("/:id/extend", s.extendSession)
 		sessions.POST("/:id/open", s.openURL)
 		sessions.POST("/:id/capture", s.captureSession)
 		sessions.POST("/:id/start-stream", s.startStream)
@@ -64,9 +108,16 @@ func (s *Server) setupRoutes() {
 	{
 		captures.GET("/:id", s.getCaptureMetadata)
 		captures.GET("/:id/screenshot", s.getScreenshot)
+		captures.GET("/:id/fullpage", s.getFullPageScreenshot)
+		captures.GET("/:id/pdf", s.getPDF)
 		captures.GET("/:id/dom", s.getDOM)
+		captures.GET("/:id/mhtml", s.getMHTML)
+		captures.GET("/:id/console", s.getConsole)
 		captures.GET("/:id/manifest", s.getManifest)
+		captures.GET("/:id/artifacts/:name", s.getArtifact)
+		captures.GET("/:id/proofs/:artifact", s.getProof)
 		captures.GET("/:id/bundle", s.getBundle)
+		captures.GET("/:id/wacz", s.getWACZ)
 	}
 }
 
@@ -74,11 +125,16 @@ func (s *Server) Run(addr string) error {
 	return s.router.Run(addr)
 }
 
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Client-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -92,3 +148,16 @@ func corsMiddleware() gin.HandlerFunc {
 func (s *Server) healthCheck(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok"})
 }
+
+func (s *Server) status(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"pool":      s.orchestrator.PoolStatus(),
+		"admission": s.orchestrator.AdmissionStatus(),
+	})
+}
+
+// getKeys publishes the keys the node signs, and has signed, manifests with.
+func (s *Server) getKeys(c *gin.Context) {
+	keys := shared.NodeKeys{Keys: append([]shared.NodeKey{}, s.nodeKeys.Keys...)}
+	c.JSON(200, keys)
+}
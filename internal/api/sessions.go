@@ -1,16 +1,27 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/intraceai/capture-node/internal/orchestrator"
 	"github.com/intraceai/capture-node/pkg/models"
 	"github.com/intraceai/capture-node/pkg/shared"
 )
 
+const statsTimeout = 5 * time.Second
+
 var wsUpgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
@@ -18,8 +29,57 @@ var wsUpgrader = websocket.Upgrader{
 }
 
 func (s *Server) createSession(c *gin.Context) {
-	session, err := s.orchestrator.CreateSession(c.Request.Context())
+	var req models.CreateSessionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	session, err := s.orchestrator.CreateSession(c.Request.Context(), orchestrator.SessionOptions{
+		ClientID:    s.clientID(c),
+		Proxy:       req.Proxy,
+		QueueTicket: req.QueueTicket,
+		Profile: orchestrator.ProfileRequest{
+			Profile:           req.Profile,
+			Device:            req.Device,
+			Image:             req.Image,
+			Viewport:          req.Viewport,
+			DeviceScaleFactor: req.DeviceScaleFactor,
+			Locale:            req.Locale,
+			Timezone:          req.Timezone,
+			UserAgent:         req.UserAgent,
+			MemoryMB:          req.MemoryMB,
+			CPUs:              req.CPUs,
+		},
+	})
 	if err != nil {
+		var validationErr *shared.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(400, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		var queueErr *orchestrator.QueueError
+		if errors.As(err, &queueErr) {
+			retryAfter := int(math.Ceil(queueErr.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(202, models.QueuedSessionResponse{
+				QueueTicket:       queueErr.Ticket,
+				QueuePosition:     queueErr.Position,
+				RetryAfterSeconds: retryAfter,
+			})
+			return
+		}
+
+		var admissionErr *orchestrator.AdmissionError
+		if errors.As(err, &admissionErr) {
+			retryAfter := int(math.Ceil(admissionErr.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(429, gin.H{"error": admissionErr.Reason})
+			return
+		}
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
@@ -27,14 +87,109 @@ func (s *Server) createSession(c *gin.Context) {
 	streamURL := s.orchestrator.GetStreamURL(session, s.publicHost)
 
 	resp := models.CreateSessionResponse{
-		SessionID: session.SessionID,
-		StreamURL: streamURL,
-		ExpiresAt: session.ExpiresAt,
+		SessionID:    session.SessionID,
+		StreamURL:    streamURL,
+		ExpiresAt:    session.ExpiresAt,
+		MaxExpiresAt: session.MaxExpiresAt,
+		Profile:      session.Profile,
 	}
 
 	c.JSON(201, resp)
 }
 
+// clientID identifies the API client for per-client session limits. Only a
+// trusted proxy may name the client; everyone else is known by address.
+func (s *Server) clientID(c *gin.Context) string {
+	if id := c.GetHeader("X-Client-ID"); id != "" && s.fromTrustedProxy(c) {
+		return id
+	}
+	return c.ClientIP()
+}
+
+func (s *Server) fromTrustedProxy(c *gin.Context) bool {
+	addrPort, err := netip.ParseAddrPort(c.Request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, prefix := range s.proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) listDevices(c *gin.Context) {
+	c.JSON(200, models.ListDevicesResponse{Devices: orchestrator.Devices()})
+}
+
+func (s *Server) listSessions(c *gin.Context) {
+	sessions := s.orchestrator.ListSessions()
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), statsTimeout)
+	defer cancel()
+
+	infos := make([]models.SessionInfo, len(sessions))
+	var wg sync.WaitGroup
+	for i := range sessions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			infos[i] = s.sessionInfo(ctx, sessions[i])
+		}(i)
+	}
+	wg.Wait()
+
+	c.JSON(200, models.ListSessionsResponse{Sessions: infos})
+}
+
+func (s *Server) getSession(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	session, ok := s.orchestrator.SessionSnapshot(sessionID)
+	if !ok {
+		c.JSON(404, gin.H{"error": "session not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), statsTimeout)
+	defer cancel()
+
+	c.JSON(200, s.sessionInfo(ctx, session))
+}
+
+// sessionInfo describes a session for operators. Resource usage is best
+// effort and omitted when the runtime cannot report it in time.
+func (s *Server) sessionInfo(ctx context.Context, session shared.Session) models.SessionInfo {
+	info := models.SessionInfo{
+		SessionID:      session.SessionID,
+		ClientID:       session.ClientID,
+		Profile:        session.Profile,
+		CreatedAt:      session.CreatedAt,
+		ExpiresAt:      session.ExpiresAt,
+		MaxExpiresAt:   session.MaxExpiresAt,
+		LastActivityAt: session.LastActivityAt,
+		RequestedURL:   session.RequestedURL,
+		OpenedURLs:     session.OpenedURLs,
+		CurrentURL:     session.CurrentURL,
+		Streaming:      session.Streaming,
+		Viewers:        s.orchestrator.Viewers(session.SessionID),
+		CaptureCount:   session.CaptureCount,
+	}
+
+	usage, err := s.orchestrator.SessionStats(ctx, session.SessionID)
+	if err != nil {
+		if !errors.Is(err, orchestrator.ErrStatsUnavailable) {
+			log.Printf("failed to get stats for session %s: %v", session.SessionID, err)
+		}
+	} else {
+		info.Resources = usage
+	}
+
+	return info
+}
+
 func (s *Server) deleteSession(c *gin.Context) {
 	sessionID := c.Param("id")
 
@@ -46,6 +201,34 @@ func (s *Server) deleteSession(c *gin.Context) {
 	c.JSON(204, nil)
 }
 
+func (s *Server) extendSession(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	var req models.ExtendSessionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.DurationSeconds < 0 {
+		c.JSON(400, gin.H{"error": "duration_seconds must not be negative"})
+		return
+	}
+
+	session, err := s.orchestrator.ExtendSession(sessionID, time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, models.ExtendSessionResponse{
+		SessionID:    session.SessionID,
+		ExpiresAt:    session.ExpiresAt,
+		MaxExpiresAt: session.MaxExpiresAt,
+	})
+}
+
 func (s *Server) openURL(c *gin.Context) {
 	sessionID := c.Param("id")
 
@@ -61,6 +244,16 @@ func (s *Server) openURL(c *gin.Context) {
 		return
 	}
 
+	if err := s.orchestrator.CheckEgress(c.Request.Context(), sessionID, url); err != nil {
+		var validationErr *shared.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(400, gin.H{"error": validationErr.Error()})
+			return
+		}
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := s.orchestrator.OpenURL(c.Request.Context(), sessionID, url); err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -124,6 +317,17 @@ func (s *Server) proxyWebSocket(c *gin.Context) {
 		log.Printf("Failed to start stream: %v", err)
 	}
 
+	notices, unsubscribe := s.orchestrator.Subscribe(sessionID)
+	defer unsubscribe()
+
+	// Both the browser relay and session notices write to the client
+	var writeMu sync.Mutex
+	writeClient := func(messageType int, data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return clientConn.WriteMessage(messageType, data)
+	}
+
 	done := make(chan struct{})
 
 	// Browser -> Client
@@ -134,7 +338,7 @@ func (s *Server) proxyWebSocket(c *gin.Context) {
 			if err != nil {
 				return
 			}
-			if err := clientConn.WriteMessage(messageType, message); err != nil {
+			if err := writeClient(messageType, message); err != nil {
 				return
 			}
 		}
@@ -148,12 +352,26 @@ func (s *Server) proxyWebSocket(c *gin.Context) {
 				browserConn.Close()
 				return
 			}
+			s.orchestrator.Touch(sessionID)
 			if err := browserConn.WriteMessage(messageType, message); err != nil {
 				return
 			}
 		}
 	}()
 
+	// Session notices -> Client
+	go func() {
+		for notice := range notices {
+			data, err := json.Marshal(notice)
+			if err != nil {
+				continue
+			}
+			if err := writeClient(websocket.TextMessage, data); err != nil {
+				return
+			}
+		}
+	}()
+
 	<-done
 
 	// Stop streaming when done
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intraceai/capture-node/internal/warc"
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const (
+	waczVersion     = "1.1.1"
+	waczSoftware    = "intrace capture-node"
+	waczDataWARC    = "data.warc.gz"
+	waczMetaWARC    = "metadata.warc.gz"
+	waczIndexPath   = "indexes/index.cdxj"
+	waczPagesPath   = "pages/pages.jsonl"
+	waczPackagePath = "datapackage.json"
+	waczDigestPath  = "datapackage-digest.json"
+)
+
+type waczResource struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Hash  string `json:"hash"`
+	Bytes int    `json:"bytes"`
+}
+
+type waczPackage struct {
+	Profile        string         `json:"profile"`
+	WACZVersion    string         `json:"wacz_version"`
+	Title          string         `json:"title"`
+	Created        string         `json:"created"`
+	Software       string         `json:"software"`
+	MainPageURL    string         `json:"mainPageURL"`
+	MainPageDate   string         `json:"mainPageDate"`
+	CaptureID      string         `json:"capture_id"`
+	ManifestSHA256 string         `json:"manifest_sha256"`
+	Resources      []waczResource `json:"resources"`
+}
+
+type waczDigest struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+// buildWARC records the HTTP exchanges of a capture. It returns the WARC
+// file and its CDXJ index, which are both manifest artifacts.
+func buildWARC(captureID string, capturedAt time.Time, exchanges []shared.HTTPExchange) ([]byte, []byte, error) {
+	var buf bytes.Buffer
+	w := warc.NewWriter(&buf, waczDataWARC)
+
+	if err := w.WriteWarcinfo(capturedAt, []warc.Field{
+		{Name: "software", Value: waczSoftware},
+		{Name: "format", Value: "WARC File Format 1.1"},
+		{Name: "isPartOf", Value: captureID},
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	for _, ex := range exchanges {
+		if err := w.WriteExchange(ex); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return buf.Bytes(), warc.CDXJ(w.Index()), nil
+}
+
+// getWACZ packages a capture as a WACZ file: the captured WARC, a metadata
+// WARC holding the manifest and event, a pages index and a datapackage
+// whose digest ties the archive back to manifest_sha256.
+func (s *Server) getWACZ(c *gin.Context) {
+	captureID := c.Param("id")
+	ctx := c.Request.Context()
+
+	manifestData, err := s.storage.GetManifest(ctx, captureID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "capture not found"})
+		return
+	}
+
+	dataWARC, err := s.storage.GetArtifact(ctx, captureID, shared.WARCFile)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "capture has no web archive; capture with the warc artifact to export WACZ"})
+		return
+	}
+	// Captures made before the index was a manifest artifact have it
+	// stored unlisted; it is then only as trustworthy as the storage.
+	dataIndex, _ := s.storage.GetArtifact(ctx, captureID, shared.WARCIndexFile)
+	if artifact, ok := manifestData.Artifact(shared.WARCIndexFile); ok && shared.SHA256Hex(dataIndex) != artifact.SHA256 {
+		c.JSON(500, gin.H{"error": "WARC index does not match the manifest"})
+		return
+	}
+	event, _ := s.storage.GetEvent(ctx, captureID)
+
+	// The metadata record keeps the stored manifest bytes, which for current
+	// captures are exactly what manifest_sha256 covers.
+	manifestJSON, err := s.storage.GetArtifact(ctx, captureID, shared.ManifestFile)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "manifest not found"})
+		return
+	}
+	manifestHash := ""
+	if event != nil {
+		manifestHash = event.Hashes.ManifestSHA256
+	}
+	if manifestHash == "" {
+		canonical, err := shared.CanonicalManifest(manifestJSON)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "failed to encode manifest"})
+			return
+		}
+		manifestHash = shared.SHA256Hex(canonical)
+	}
+
+	var metaBuf bytes.Buffer
+	meta := warc.NewWriter(&metaBuf, waczMetaWARC)
+	capturedAt := manifestData.CapturedAtUTC
+	err = meta.WriteWarcinfo(capturedAt, []warc.Field{
+		{Name: "software", Value: waczSoftware},
+		{Name: "format", Value: "WARC File Format 1.1"},
+		{Name: "isPartOf", Value: captureID},
+	})
+	if err == nil {
+		err = meta.WriteResource(captureURN(captureID, shared.ManifestFile), "application/json", capturedAt, manifestJSON)
+	}
+	if err == nil && event != nil {
+		eventJSON, _ := json.MarshalIndent(event, "", "  ")
+		err = meta.WriteResource(captureURN(captureID, shared.EventFile), "application/json", capturedAt, eventJSON)
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to build metadata WARC"})
+		return
+	}
+
+	index := mergeCDXJ(dataIndex, warc.CDXJ(meta.Index()))
+	pages := pagesJSONL(captureID, manifestData)
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	var resources []waczResource
+
+	// WARC files are stored uncompressed in the ZIP so replay tools can
+	// range-read individual records.
+	files := []struct {
+		path   string
+		data   []byte
+		stored bool
+	}{
+		{"archive/" + waczDataWARC, dataWARC, true},
+		{"archive/" + waczMetaWARC, metaBuf.Bytes(), true},
+		{waczIndexPath, index, false},
+		{waczPagesPath, pages, false},
+	}
+	for _, f := range files {
+		if err := addZipFile(zipWriter, f.path, f.data, f.stored); err != nil {
+			c.JSON(500, gin.H{"error": "failed to write WACZ"})
+			return
+		}
+		resources = append(resources, waczResource{
+			Name:  f.path[strings.LastIndex(f.path, "/")+1:],
+			Path:  f.path,
+			Hash:  warc.Digest(f.data),
+			Bytes: len(f.data),
+		})
+	}
+
+	pkg := waczPackage{
+		Profile:        "data-package",
+		WACZVersion:    waczVersion,
+		Title:          fmt.Sprintf("Capture %s", captureID),
+		Created:        time.Now().UTC().Format(time.RFC3339),
+		Software:       waczSoftware,
+		MainPageURL:    manifestData.FinalURL,
+		MainPageDate:   capturedAt.UTC().Format(time.RFC3339),
+		CaptureID:      captureID,
+		ManifestSHA256: manifestHash,
+		Resources:      resources,
+	}
+	pkgJSON, _ := json.MarshalIndent(pkg, "", "  ")
+	digestJSON, _ := json.MarshalIndent(waczDigest{
+		Path: waczPackagePath,
+		Hash: warc.Digest(pkgJSON),
+	}, "", "  ")
+
+	if err := addZipFile(zipWriter, waczPackagePath, pkgJSON, false); err != nil {
+		c.JSON(500, gin.H{"error": "failed to write WACZ"})
+		return
+	}
+	if err := addZipFile(zipWriter, waczDigestPath, digestJSON, false); err != nil {
+		c.JSON(500, gin.H{"error": "failed to write WACZ"})
+		return
+	}
+
+	zipWriter.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=capture-%s.wacz", captureID[:8]))
+	c.Data(200, "application/wacz+zip", buf.Bytes())
+}
+
+func captureURN(captureID, name string) string {
+	return fmt.Sprintf("urn:intrace:capture:%s/%s", captureID, name)
+}
+
+func pagesJSONL(captureID string, m *shared.Manifest) []byte {
+	var b bytes.Buffer
+	header, _ := json.Marshal(map[string]string{
+		"format": "json-pages-1.0",
+		"id":     "pages",
+		"title":  "All Pages",
+	})
+	b.Write(header)
+	b.WriteByte('\n')
+
+	page, _ := json.Marshal(map[string]string{
+		"id":  captureID,
+		"url": m.FinalURL,
+		"ts":  m.CapturedAtUTC.UTC().Format(time.RFC3339),
+	})
+	b.Write(page)
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+func mergeCDXJ(indexes ...[]byte) []byte {
+	var lines []string
+	for _, index := range indexes {
+		for _, line := range strings.Split(string(index), "\n") {
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	sort.Strings(lines)
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func addZipFile(zw *zip.Writer, name string, data []byte, stored bool) error {
+	method := zip.Deflate
+	if stored {
+		method = zip.Store
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
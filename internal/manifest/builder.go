@@ -1,6 +1,7 @@
 package manifest
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/intraceai/capture-node/pkg/shared"
@@ -12,47 +13,89 @@ func NewBuilder() *Builder {
 	return &Builder{}
 }
 
+// ArtifactData is an artifact to record in the manifest, keyed by its file
+// name within the capture.
+type ArtifactData struct {
+	Name      string
+	MediaType string
+	Data      []byte
+}
+
 type BuildInput struct {
 	CaptureID      string
 	URL            string
 	FinalURL       string
+	Redirects      []shared.Redirect
 	CapturedAtUTC  time.Time
 	BrowserName    string
 	BrowserVersion string
+	UserAgent      string
 	ViewportWidth  int
 	ViewportHeight int
-	ScreenshotData []byte
-	DOMData        []byte
+	Profile        *shared.BrowserProfile
+	FullPage       *shared.FullPage
+	CaptureOptions *shared.CaptureOptions
+	Artifacts      []ArtifactData
 }
 
 type BuildOutput struct {
-	Manifest       *shared.Manifest
-	ScreenshotHash string
-	DOMHash        string
-	ManifestHash   string
+	Manifest     *shared.Manifest
+	ManifestHash string
+	// Canonical is the canonical encoding ManifestHash covers.
+	Canonical []byte
 }
 
 func (b *Builder) Build(input BuildInput) (*BuildOutput, error) {
-	screenshotHash := shared.SHA256Hex(input.ScreenshotData)
-	domHash := shared.SHA256Hex(input.DOMData)
-
 	manifest := &shared.Manifest{
-		CaptureID:     input.CaptureID,
-		URL:           input.URL,
-		FinalURL:      input.FinalURL,
-		CapturedAtUTC: input.CapturedAtUTC,
+		Version:          shared.ManifestVersion,
+		Canonicalization: shared.CanonicalizationJCS,
+		CaptureID:        input.CaptureID,
+		URL:              input.URL,
+		FinalURL:         input.FinalURL,
+		Redirects:        input.Redirects,
+		CapturedAtUTC:    input.CapturedAtUTC,
 		Browser: shared.Browser{
-			Name:    input.BrowserName,
-			Version: input.BrowserVersion,
+			Name:      input.BrowserName,
+			Version:   input.BrowserVersion,
+			UserAgent: input.UserAgent,
 		},
 		Viewport: shared.Viewport{
 			Width:  input.ViewportWidth,
 			Height: input.ViewportHeight,
 		},
+		Artifacts:  make([]shared.Artifact, 0, len(input.Artifacts)),
 		Visibility: "public",
+		Profile:    input.Profile,
+		Device:     input.Profile.EmulatedDevice(),
+		FullPage:   input.FullPage,
+
+		CaptureOptions: input.CaptureOptions,
+	}
+
+	seen := make(map[string]bool, len(input.Artifacts))
+	for _, artifact := range input.Artifacts {
+		if artifact.Name == "" || seen[artifact.Name] {
+			return nil, fmt.Errorf("invalid or duplicate artifact name %q", artifact.Name)
+		}
+		seen[artifact.Name] = true
+
+		manifest.Artifacts = append(manifest.Artifacts, shared.Artifact{
+			Name:      artifact.Name,
+			MediaType: artifact.MediaType,
+			Size:      int64(len(artifact.Data)),
+			SHA256:    shared.SHA256Hex(artifact.Data),
+			Path:      shared.ArtifactPath(input.CaptureID, artifact.Name),
+		})
+	}
+
+	if len(manifest.Artifacts) > 0 {
+		root, err := shared.MerkleRoot(manifest.Artifacts)
+		if err != nil {
+			return nil, err
+		}
+		manifest.MerkleRoot = root
+		manifest.MerkleAlgorithm = shared.MerkleAlgorithm
 	}
-	manifest.Hashes.ScreenshotSHA256 = screenshotHash
-	manifest.Hashes.DOMSHA256 = domHash
 
 	manifestBytes, err := shared.CanonicalJSON(manifest)
 	if err != nil {
@@ -61,9 +104,8 @@ func (b *Builder) Build(input BuildInput) (*BuildOutput, error) {
 	manifestHash := shared.SHA256Hex(manifestBytes)
 
 	return &BuildOutput{
-		Manifest:       manifest,
-		ScreenshotHash: screenshotHash,
-		DOMHash:        domHash,
-		ManifestHash:   manifestHash,
+		Manifest:     manifest,
+		ManifestHash: manifestHash,
+		Canonical:    manifestBytes,
 	}, nil
 }
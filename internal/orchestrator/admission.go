@@ -0,0 +1,276 @@
+package orchestrator
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultRetryAfter = 30 * time.Second
+
+// LimitsConfig bounds how many sessions may run at once.
+type LimitsConfig struct {
+	// MaxSessions caps concurrent sessions across all clients. Zero means
+	// no cap.
+	MaxSessions int `json:"max_sessions"`
+	// MaxPerClient caps concurrent plus queued sessions for one API client.
+	// Zero means no cap.
+	MaxPerClient int `json:"max_per_client"`
+	// QueueSize is how many requests may wait for a free slot. Zero
+	// rejects immediately when saturated.
+	QueueSize int `json:"queue_size"`
+	// QueueTimeout is how long a queued request keeps its place, or a
+	// granted slot is held, without the client polling.
+	QueueTimeout time.Duration `json:"queue_timeout"`
+	// RetryAfter is the back-off suggested to rejected clients.
+	RetryAfter time.Duration `json:"retry_after"`
+}
+
+// AdmissionError reports that a session request was rejected.
+type AdmissionError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *AdmissionError) Error() string {
+	return e.Reason
+}
+
+// QueueError reports that a session request is waiting for a slot. The
+// client keeps its place by retrying with Ticket within the queue timeout.
+type QueueError struct {
+	Ticket     string
+	Position   int
+	RetryAfter time.Duration
+}
+
+func (e *QueueError) Error() string {
+	return fmt.Sprintf("queued for a session slot (position %d)", e.Position)
+}
+
+type AdmissionStatus struct {
+	LimitsConfig
+	Active int `json:"active"`
+	Queued int `json:"queued"`
+}
+
+// admissionWaiter is a queued request. The client polls with its ticket;
+// once admitted, the slot is held for it until it claims it or stops
+// polling.
+type admissionWaiter struct {
+	ticket   string
+	clientID string
+	elem     *list.Element
+	admitted bool
+	deadline time.Time
+}
+
+// admission hands out session slots in FIFO order while honouring the
+// global and per-client caps.
+type admission struct {
+	cfg       LimitsConfig
+	mu        sync.Mutex
+	active    int
+	perClient map[string]int
+	queued    map[string]int
+	queue     *list.List
+	tickets   map[string]*admissionWaiter
+}
+
+func newAdmission(cfg LimitsConfig) *admission {
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = defaultRetryAfter
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = cfg.RetryAfter
+	}
+	return &admission{
+		cfg:       cfg,
+		perClient: make(map[string]int),
+		queued:    make(map[string]int),
+		queue:     list.New(),
+		tickets:   make(map[string]*admissionWaiter),
+	}
+}
+
+func (a *admission) globalFull() bool {
+	return a.cfg.MaxSessions > 0 && a.active >= a.cfg.MaxSessions
+}
+
+func (a *admission) clientFull(clientID string, pending int) bool {
+	return a.cfg.MaxPerClient > 0 && a.perClient[clientID]+pending >= a.cfg.MaxPerClient
+}
+
+func (a *admission) admit(clientID string) {
+	a.active++
+	a.perClient[clientID]++
+}
+
+// pollInterval is how soon queued clients are asked to poll again, well
+// within QueueTimeout so their place is kept.
+func (a *admission) pollInterval() time.Duration {
+	interval := a.cfg.QueueTimeout / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > a.cfg.RetryAfter {
+		interval = a.cfg.RetryAfter
+	}
+	return interval
+}
+
+// acquire reserves a session slot for clientID. When the node is saturated
+// the request is queued and a QueueError with a ticket is returned; polling
+// with that ticket reports the current position until the slot is granted.
+// A granted slot must be returned with release.
+func (a *admission) acquire(clientID, ticket string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	a.expire(now)
+
+	if ticket != "" {
+		w, ok := a.tickets[ticket]
+		if !ok || w.clientID != clientID {
+			return &AdmissionError{
+				Reason:     "unknown or expired queue ticket",
+				RetryAfter: a.pollInterval(),
+			}
+		}
+		if w.admitted {
+			delete(a.tickets, ticket)
+			return nil
+		}
+		w.deadline = now.Add(a.cfg.QueueTimeout)
+		return a.queueError(w)
+	}
+
+	if a.clientFull(clientID, a.queued[clientID]) {
+		return &AdmissionError{
+			Reason:     "client session limit reached",
+			RetryAfter: a.cfg.RetryAfter,
+		}
+	}
+
+	// Waiters still queued while global capacity is free are held back by
+	// their own per-client cap, so there is nobody to jump ahead of.
+	if !a.globalFull() {
+		a.admit(clientID)
+		return nil
+	}
+
+	if a.queue.Len() >= a.cfg.QueueSize {
+		return &AdmissionError{
+			Reason:     "session capacity exhausted",
+			RetryAfter: a.cfg.RetryAfter,
+		}
+	}
+
+	w := &admissionWaiter{
+		ticket:   uuid.New().String(),
+		clientID: clientID,
+		deadline: now.Add(a.cfg.QueueTimeout),
+	}
+	w.elem = a.queue.PushBack(w)
+	a.queued[clientID]++
+	a.tickets[w.ticket] = w
+	return a.queueError(w)
+}
+
+func (a *admission) queueError(w *admissionWaiter) *QueueError {
+	return &QueueError{
+		Ticket:     w.ticket,
+		Position:   a.position(w.elem),
+		RetryAfter: a.pollInterval(),
+	}
+}
+
+// expire drops waiters whose client stopped polling and returns slots that
+// were granted but never claimed.
+func (a *admission) expire(now time.Time) {
+	for ticket, w := range a.tickets {
+		if now.Before(w.deadline) {
+			continue
+		}
+		delete(a.tickets, ticket)
+		if w.admitted {
+			a.releaseLocked(w.clientID)
+		} else {
+			a.queue.Remove(w.elem)
+			a.dequeued(w.clientID)
+		}
+	}
+}
+
+// sweep runs expire for nodes that see no new session requests.
+func (a *admission) sweep() {
+	a.mu.Lock()
+	a.expire(time.Now())
+	a.mu.Unlock()
+}
+
+// adopt accounts for a session that bypassed the queue, such as one
+// recovered after a restart.
+func (a *admission) adopt(clientID string) {
+	a.mu.Lock()
+	a.admit(clientID)
+	a.mu.Unlock()
+}
+
+func (a *admission) release(clientID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.releaseLocked(clientID)
+}
+
+func (a *admission) releaseLocked(clientID string) {
+	a.active--
+	if a.perClient[clientID]--; a.perClient[clientID] <= 0 {
+		delete(a.perClient, clientID)
+	}
+
+	// Admit waiters in arrival order, skipping clients that are at their cap
+	// so one busy client cannot block the rest of the queue.
+	for elem := a.queue.Front(); elem != nil && !a.globalFull(); {
+		next := elem.Next()
+		w := elem.Value.(*admissionWaiter)
+		if !a.clientFull(w.clientID, 0) {
+			a.queue.Remove(elem)
+			a.dequeued(w.clientID)
+			a.admit(w.clientID)
+			w.admitted = true
+			w.elem = nil
+			w.deadline = time.Now().Add(a.cfg.QueueTimeout)
+		}
+		elem = next
+	}
+}
+
+func (a *admission) dequeued(clientID string) {
+	if a.queued[clientID]--; a.queued[clientID] <= 0 {
+		delete(a.queued, clientID)
+	}
+}
+
+func (a *admission) position(target *list.Element) int {
+	position := 1
+	for elem := a.queue.Front(); elem != nil && elem != target; elem = elem.Next() {
+		position++
+	}
+	return position
+}
+
+func (a *admission) status() AdmissionStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(time.Now())
+	return AdmissionStatus{
+		LimitsConfig: a.cfg,
+		Active:       a.active,
+		Queued:       a.queue.Len(),
+	}
+}
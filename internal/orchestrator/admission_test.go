@@ -0,0 +1,186 @@
+package orchestrator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	wantAdmit  = "admit"
+	wantQueue  = "queue"
+	wantReject = "reject"
+)
+
+func outcome(err error) string {
+	var queueErr *QueueError
+	var admissionErr *AdmissionError
+	switch {
+	case err == nil:
+		return wantAdmit
+	case errors.As(err, &queueErr):
+		return wantQueue
+	case errors.As(err, &admissionErr):
+		return wantReject
+	}
+	return err.Error()
+}
+
+func TestAdmissionLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     LimitsConfig
+		clients []string
+		want    []string
+	}{
+		{
+			name:    "no limits",
+			clients: []string{"a", "a", "b"},
+			want:    []string{wantAdmit, wantAdmit, wantAdmit},
+		},
+		{
+			name:    "global cap without a queue",
+			cfg:     LimitsConfig{MaxSessions: 1},
+			clients: []string{"a", "b"},
+			want:    []string{wantAdmit, wantReject},
+		},
+		{
+			name:    "global cap queues until the queue is full",
+			cfg:     LimitsConfig{MaxSessions: 1, QueueSize: 1},
+			clients: []string{"a", "b", "c"},
+			want:    []string{wantAdmit, wantQueue, wantReject},
+		},
+		{
+			name:    "per-client cap",
+			cfg:     LimitsConfig{MaxPerClient: 2},
+			clients: []string{"a", "a", "a", "b"},
+			want:    []string{wantAdmit, wantAdmit, wantReject, wantAdmit},
+		},
+		{
+			name:    "queued requests count toward the per-client cap",
+			cfg:     LimitsConfig{MaxSessions: 1, MaxPerClient: 2, QueueSize: 5},
+			clients: []string{"a", "a", "a", "b"},
+			want:    []string{wantAdmit, wantQueue, wantReject, wantQueue},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdmission(tt.cfg)
+			for i, client := range tt.clients {
+				if got := outcome(a.acquire(client, "")); got != tt.want[i] {
+					t.Errorf("request %d from %s: %s, want %s", i, client, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAdmissionFIFO(t *testing.T) {
+	a := newAdmission(LimitsConfig{MaxSessions: 1, QueueSize: 3})
+	if err := a.acquire("first", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var tickets []string
+	for i, client := range []string{"b", "c", "d"} {
+		var queueErr *QueueError
+		if !errors.As(a.acquire(client, ""), &queueErr) {
+			t.Fatalf("%s was not queued", client)
+		}
+		if queueErr.Position != i+1 {
+			t.Errorf("%s queued at %d, want %d", client, queueErr.Position, i+1)
+		}
+		tickets = append(tickets, queueErr.Ticket)
+	}
+
+	a.release("first")
+
+	// The head of the queue gets the slot and everyone else moves up.
+	if err := a.acquire("b", tickets[0]); err != nil {
+		t.Fatalf("head of the queue not admitted: %v", err)
+	}
+	for i, client := range []string{"c", "d"} {
+		var queueErr *QueueError
+		if !errors.As(a.acquire(client, tickets[i+1]), &queueErr) {
+			t.Fatalf("%s left the queue early", client)
+		}
+		if queueErr.Position != i+1 {
+			t.Errorf("%s at %d after a release, want %d", client, queueErr.Position, i+1)
+		}
+	}
+
+	if got := outcome(a.acquire("c", tickets[2])); got != wantReject {
+		t.Errorf("ticket used by another client: %s, want %s", got, wantReject)
+	}
+}
+
+func TestAdmissionTicketExpiry(t *testing.T) {
+	cfg := LimitsConfig{MaxSessions: 1, QueueSize: 2, QueueTimeout: time.Minute}
+
+	t.Run("queued ticket", func(t *testing.T) {
+		a := newAdmission(cfg)
+		a.acquire("a", "")
+		var queueErr *QueueError
+		if !errors.As(a.acquire("b", ""), &queueErr) {
+			t.Fatal("b was not queued")
+		}
+
+		a.mu.Lock()
+		a.expire(time.Now().Add(2 * time.Minute))
+		a.mu.Unlock()
+
+		if got := outcome(a.acquire("b", queueErr.Ticket)); got != wantReject {
+			t.Errorf("expired ticket: %s, want %s", got, wantReject)
+		}
+		if status := a.status(); status.Queued != 0 || status.Active != 1 {
+			t.Errorf("active %d, queued %d after expiry; want 1, 0", status.Active, status.Queued)
+		}
+	})
+
+	t.Run("granted slot never claimed", func(t *testing.T) {
+		a := newAdmission(cfg)
+		a.acquire("a", "")
+		var queueErr *QueueError
+		if !errors.As(a.acquire("b", ""), &queueErr) {
+			t.Fatal("b was not queued")
+		}
+		a.release("a")
+		if status := a.status(); status.Active != 1 {
+			t.Fatalf("slot not held for b: active %d", status.Active)
+		}
+
+		a.mu.Lock()
+		a.expire(time.Now().Add(2 * time.Minute))
+		a.mu.Unlock()
+
+		if status := a.status(); status.Active != 0 {
+			t.Errorf("unclaimed slot not returned: active %d", status.Active)
+		}
+		if err := a.acquire("c", ""); err != nil {
+			t.Errorf("freed slot not available: %v", err)
+		}
+	})
+
+	t.Run("polling keeps the place", func(t *testing.T) {
+		a := newAdmission(cfg)
+		a.acquire("a", "")
+		var queueErr *QueueError
+		if !errors.As(a.acquire("b", ""), &queueErr) {
+			t.Fatal("b was not queued")
+		}
+
+		a.mu.Lock()
+		a.tickets[queueErr.Ticket].deadline = time.Now().Add(time.Second)
+		a.mu.Unlock()
+		if got := outcome(a.acquire("b", queueErr.Ticket)); got != wantQueue {
+			t.Fatalf("poll: %s, want %s", got, wantQueue)
+		}
+
+		a.mu.Lock()
+		a.expire(time.Now().Add(30 * time.Second))
+		a.mu.Unlock()
+		if got := outcome(a.acquire("b", queueErr.Ticket)); got != wantQueue {
+			t.Errorf("ticket expired despite polling: %s", got)
+		}
+	})
+}
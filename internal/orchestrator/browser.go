@@ -11,137 +11,230 @@ import (
 	"sync"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/network"
-	"github.com/docker/docker/client"
-	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
 	"github.com/intraceai/capture-node/pkg/shared"
 )
 
 const (
-	sessionTimeout   = 15 * time.Minute
-	cleanupInterval  = 1 * time.Minute
-	browserImage     = "intraceai/remote-browser:latest"
-	browserAPIPort   = 8082
+	sessionTimeout  = 15 * time.Minute
+	cleanupInterval = 15 * time.Second
+	browserImage    = "intraceai/remote-browser:latest"
+
+	defaultMaxFullPageHeight = 16384
+
+	// maxOpenedURLs bounds the per-session history of opened URLs.
+	maxOpenedURLs = 50
 )
 
-type Orchestrator struct {
-	docker       *client.Client
-	httpClient   *http.Client
-	sessions     map[string]*shared.Session
-	mu           sync.RWMutex
-	networkName  string
-	stopChan     chan struct{}
+// Config holds the tunable orchestrator settings.
+type Config struct {
+	Session  SessionConfig
+	Pool     PoolConfig
+	Limits   LimitsConfig
+	Profiles ProfileConfig
+	Egress   EgressConfig
+	Capture  CaptureConfig
 }
 
-func NewOrchestrator(networkName string) (*Orchestrator, error) {
-	docker, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		return nil, fmt.Errorf("failed to create docker client: %w", err)
-	}
+// CaptureConfig bounds what a single capture may produce.
+type CaptureConfig struct {
+	// MaxFullPageHeight caps full-page screenshots, in CSS pixels.
+	MaxFullPageHeight int
+}
+
+// SessionOptions carries per-request settings for CreateSession.
+type SessionOptions struct {
+	// ClientID identifies the API client for per-client limits.
+	ClientID string
+	// Profile selects and customizes the browser configuration.
+	Profile ProfileRequest
+	// Proxy selects an allowed upstream proxy; empty uses the default.
+	Proxy string
+	// QueueTicket resumes a request queued by an earlier QueueError.
+	QueueTicket string
+}
+
+type Orchestrator struct {
+	runtime    Runtime
+	httpClient *http.Client
+	sessions   map[string]*shared.Session
+	sessionCfg SessionConfig
+	profileCfg ProfileConfig
+	egressCfg  EgressConfig
+	captureCfg CaptureConfig
+	watchers   map[string]map[chan SessionNotice]struct{}
+	warned     map[string]time.Time
+	pool       *warmPool
+	claims     *claimStore
+	admission  *admission
+	mu         sync.RWMutex
+	stopChan   chan struct{}
+}
 
+func NewOrchestrator(runtime Runtime, cfg Config) *Orchestrator {
 	return &Orchestrator{
-		docker:      docker,
-		httpClient:  &http.Client{Timeout: 30 * time.Second},
-		sessions:    make(map[string]*shared.Session),
-		networkName: networkName,
-		stopChan:    make(chan struct{}),
-	}, nil
+		runtime:    runtime,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		sessions:   make(map[string]*shared.Session),
+		sessionCfg: cfg.Session.withDefaults(),
+		profileCfg: cfg.Profiles.withDefaults(),
+		egressCfg:  cfg.Egress.withDefaults(),
+		captureCfg: cfg.Capture.withDefaults(),
+		watchers:   make(map[string]map[chan SessionNotice]struct{}),
+		warned:     make(map[string]time.Time),
+		pool:       newWarmPool(cfg.Pool),
+		claims:     newClaimStore(cfg.Pool.ClaimsFile),
+		admission:  newAdmission(cfg.Limits),
+		stopChan:   make(chan struct{}),
+	}
 }
 
 func (o *Orchestrator) Start(ctx context.Context) {
+	o.reconcile(ctx)
 	go o.cleanupLoop(ctx)
+	go o.poolLoop(ctx)
 }
 
 func (o *Orchestrator) Stop() {
 	close(o.stopChan)
+	o.drainPool()
 }
 
-func (o *Orchestrator) CreateSession(ctx context.Context) (*shared.Session, error) {
-	sessionID := uuid.New().String()
-	containerName := fmt.Sprintf("intrace-browser-%s", sessionID[:8])
-
-	exposedPorts := nat.PortSet{
-		nat.Port(fmt.Sprintf("%d/tcp", browserAPIPort)): struct{}{},
+func (o *Orchestrator) CreateSession(ctx context.Context, opts SessionOptions) (*shared.Session, error) {
+	profile, err := o.ResolveProfile(opts.Profile)
+	if err != nil {
+		return nil, err
 	}
 
-	config := &container.Config{
-		Image: browserImage,
-		Env: []string{
-			fmt.Sprintf("SESSION_ID=%s", sessionID),
-		},
-		ExposedPorts: exposedPorts,
+	egress, err := o.resolveEgress(opts.Proxy)
+	if err != nil {
+		return nil, err
 	}
 
-	hostConfig := &container.HostConfig{
-		AutoRemove: true,
-		Resources: container.Resources{
-			Memory:   2 * 1024 * 1024 * 1024,
-			NanoCPUs: 2 * 1000000000,
-		},
+	if err := o.admission.acquire(opts.ClientID, opts.QueueTicket); err != nil {
+		return nil, err
 	}
 
-	var networkConfig *network.NetworkingConfig
-	if o.networkName != "" {
-		networkConfig = &network.NetworkingConfig{
-			EndpointsConfig: map[string]*network.EndpointSettings{
-				o.networkName: {},
-			},
+	// Pooled browsers all run the default profile and egress policy.
+	var session *shared.Session
+	if sameProfile(profile, o.defaultProfile()) && sameEgress(egress, o.defaultEgress()) {
+		session = o.takePooled(ctx)
+	}
+	pooled := session != nil
+	if session == nil {
+		session, err = o.launchBrowser(ctx, opts.ClientID, profile, egress, false)
+		if err != nil {
+			o.admission.release(opts.ClientID)
+			return nil, err
 		}
 	}
 
-	resp, err := o.docker.ContainerCreate(ctx, config, hostConfig, networkConfig, nil, containerName)
+	now := time.Now().UTC()
+	session.ClientID = opts.ClientID
+	session.CreatedAt = now
+	session.ExpiresAt = now.Add(o.sessionCfg.Timeout)
+	session.MaxExpiresAt = now.Add(o.sessionCfg.MaxLifetime)
+	session.LastActivityAt = now
+
+	if pooled {
+		o.claims.put(session.ContainerID, poolClaim{
+			SessionID:    session.SessionID,
+			ClientID:     session.ClientID,
+			APIPort:      session.APIPort,
+			CreatedAt:    session.CreatedAt,
+			ExpiresAt:    session.ExpiresAt,
+			MaxExpiresAt: session.MaxExpiresAt,
+		})
+	}
+
+	o.mu.Lock()
+	o.sessions[session.SessionID] = session
+	o.mu.Unlock()
+
+	return session, nil
+}
+
+// launchBrowser starts a browser instance and waits until its agent answers
+// health checks. Session lifetimes are recorded in the instance labels so
+// reconcile knows how long it may be adopted after a restart; pooled
+// browsers are labeled as such and only adopted once claimed.
+func (o *Orchestrator) launchBrowser(ctx context.Context, clientID string, profile shared.BrowserProfile, egress shared.EgressPolicy, pooled bool) (*shared.Session, error) {
+	sessionID := uuid.New().String()
+	containerName := fmt.Sprintf("intrace-browser-%s", sessionID[:8])
+	now := time.Now().UTC()
+
+	state, lifetime, maxLifetime := stateSession, o.sessionCfg.Timeout, o.sessionCfg.MaxLifetime
+	if pooled {
+		state, lifetime, maxLifetime = statePooled, poolMaxIdleAge, poolMaxIdleAge
+	}
+
+	env := []string{
+		fmt.Sprintf("SESSION_ID=%s", sessionID),
+	}
+	env = append(env, profileEnv(profile)...)
+	env = append(env, egressEnv(egress)...)
+
+	rules, err := egressRules(ctx, egress)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceID, err := o.runtime.Create(ctx, BrowserSpec{
+		Name:        containerName,
+		Image:       profile.Image,
+		Env:         env,
+		Labels:      sessionLabels(sessionID, clientID, state, profile, egress, now, now.Add(lifetime), now.Add(maxLifetime)),
+		Egress:      rules,
+		MemoryBytes: int64(profile.MemoryMB) * 1024 * 1024,
+		NanoCPUs:    int64(profile.CPUs * 1e9),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create container: %w", err)
+		return nil, err
 	}
 
-	if err := o.docker.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return nil, fmt.Errorf("failed to start container: %w", err)
+	if err := o.runtime.Start(ctx, instanceID); err != nil {
+		o.discardInstance(instanceID)
+		return nil, err
 	}
 
-	inspect, err := o.docker.ContainerInspect(ctx, resp.ID)
+	host, port, err := o.runtime.Address(ctx, instanceID)
 	if err != nil {
-		o.docker.ContainerStop(ctx, resp.ID, container.StopOptions{})
-		return nil, fmt.Errorf("failed to inspect container: %w", err)
-	}
-
-	var containerIP string
-	if o.networkName != "" && inspect.NetworkSettings.Networks[o.networkName] != nil {
-		containerIP = inspect.NetworkSettings.Networks[o.networkName].IPAddress
-	} else if inspect.NetworkSettings.IPAddress != "" {
-		containerIP = inspect.NetworkSettings.IPAddress
-	} else {
-		for _, net := range inspect.NetworkSettings.Networks {
-			if net.IPAddress != "" {
-				containerIP = net.IPAddress
-				break
-			}
-		}
+		o.discardInstance(instanceID)
+		return nil, err
 	}
 
-	now := time.Now().UTC()
 	session := &shared.Session{
 		SessionID:   sessionID,
-		ContainerID: resp.ID,
-		ContainerIP: containerIP,
-		APIPort:     browserAPIPort,
+		Profile:     &profile,
+		Egress:      &egress,
+		ContainerID: instanceID,
+		ContainerIP: host,
+		APIPort:     port,
 		CreatedAt:   now,
-		ExpiresAt:   now.Add(sessionTimeout),
-	}
+		ExpiresAt:   now.Add(lifetime),
 
-	o.mu.Lock()
-	o.sessions[sessionID] = session
-	o.mu.Unlock()
+		MaxExpiresAt:   now.Add(maxLifetime),
+		LastActivityAt: now,
+	}
 
 	if err := o.waitForReady(ctx, session); err != nil {
-		o.DestroySession(ctx, sessionID)
+		o.discardInstance(instanceID)
 		return nil, fmt.Errorf("browser not ready: %w", err)
 	}
 
 	return session, nil
 }
 
+// discardInstance stops an instance whose launch failed. It does not use the
+// launch context, which is often the reason the launch failed.
+func (o *Orchestrator) discardInstance(instanceID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := o.runtime.Stop(ctx, instanceID); err != nil {
+		log.Printf("failed to stop container %s: %v", instanceID, err)
+	}
+}
+
 func (o *Orchestrator) waitForReady(ctx context.Context, session *shared.Session) error {
 	healthURL := fmt.Sprintf("http://%s:%d/health", session.ContainerIP, session.APIPort)
 
@@ -179,6 +272,12 @@ func (o *Orchestrator) DestroySession(ctx context.Context, sessionID string) err
 	session, ok := o.sessions[sessionID]
 	if ok {
 		delete(o.sessions, sessionID)
+		delete(o.warned, sessionID)
+		o.closeWatchers(sessionID, SessionNotice{
+			Type:      NoticeExpired,
+			SessionID: sessionID,
+			ExpiresAt: time.Now().UTC(),
+		})
 	}
 	o.mu.Unlock()
 
@@ -186,9 +285,10 @@ func (o *Orchestrator) DestroySession(ctx context.Context, sessionID string) err
 		return nil
 	}
 
-	stopTimeout := 5
-	err := o.docker.ContainerStop(ctx, session.ContainerID, container.StopOptions{Timeout: &stopTimeout})
-	if err != nil {
+	o.admission.release(session.ClientID)
+	o.claims.remove(session.ContainerID)
+
+	if err := o.runtime.Stop(ctx, session.ContainerID); err != nil {
 		log.Printf("failed to stop container %s: %v", session.ContainerID, err)
 	}
 
@@ -201,6 +301,8 @@ func (o *Orchestrator) OpenURL(ctx context.Context, sessionID, url string) error
 		return fmt.Errorf("session not found")
 	}
 
+	o.Touch(sessionID)
+
 	apiURL := fmt.Sprintf("http://%s:%d/open", session.ContainerIP, session.APIPort)
 	body, _ := json.Marshal(map[string]string{"url": url})
 
@@ -221,21 +323,43 @@ func (o *Orchestrator) OpenURL(ctx context.Context, sessionID, url string) error
 		return fmt.Errorf("failed to open URL: %s", string(respBody))
 	}
 
+	o.updateSession(sessionID, func(session *shared.Session) {
+		opened := append([]string(nil), session.OpenedURLs...)
+		opened = append(opened, url)
+		if len(opened) > maxOpenedURLs {
+			opened = opened[len(opened)-maxOpenedURLs:]
+		}
+		session.OpenedURLs = opened
+		session.RequestedURL = url
+		session.CurrentURL = url
+	})
+
 	return nil
 }
 
-func (o *Orchestrator) Capture(ctx context.Context, sessionID string) (*shared.BrowserCaptureResponse, error) {
+func (o *Orchestrator) Capture(ctx context.Context, sessionID string, captureReq shared.BrowserCaptureRequest) (*shared.BrowserCaptureResponse, error) {
 	session, ok := o.GetSession(sessionID)
 	if !ok {
 		return nil, fmt.Errorf("session not found")
 	}
 
+	o.Touch(sessionID)
+
+	if captureReq.FullPage {
+		maxHeight := o.captureCfg.MaxFullPageHeight
+		if captureReq.MaxFullPageHeight <= 0 || captureReq.MaxFullPageHeight > maxHeight {
+			captureReq.MaxFullPageHeight = maxHeight
+		}
+	}
+
 	apiURL := fmt.Sprintf("http://%s:%d/capture", session.ContainerIP, session.APIPort)
+	body, _ := json.Marshal(captureReq)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := o.httpClient.Do(req)
 	if err != nil {
@@ -253,9 +377,32 @@ func (o *Orchestrator) Capture(ctx context.Context, sessionID string) (*shared.B
 		return nil, err
 	}
 
+	o.updateSession(sessionID, func(session *shared.Session) {
+		session.CaptureCount++
+		if result.FinalURL != "" {
+			session.CurrentURL = result.FinalURL
+			// The page was navigated to outside OpenURL, so the
+			// requested URL no longer describes it.
+			if shared.PageURL(session.RequestedURL, result.FinalURL, result.Redirects) != session.RequestedURL {
+				session.RequestedURL = ""
+			}
+		}
+	})
+
 	return &result, nil
 }
 
+func (c CaptureConfig) withDefaults() CaptureConfig {
+	if c.MaxFullPageHeight <= 0 {
+		c.MaxFullPageHeight = defaultMaxFullPageHeight
+	}
+	return c
+}
+
+func (o *Orchestrator) AdmissionStatus() AdmissionStatus {
+	return o.admission.status()
+}
+
 func (o *Orchestrator) cleanupLoop(ctx context.Context) {
 	ticker := time.NewTicker(cleanupInterval)
 	defer ticker.Stop()
@@ -268,6 +415,7 @@ func (o *Orchestrator) cleanupLoop(ctx context.Context) {
 			return
 		case <-ticker.C:
 			o.cleanupExpiredSessions(ctx)
+			o.admission.sweep()
 		}
 	}
 }
@@ -275,10 +423,12 @@ func (o *Orchestrator) cleanupLoop(ctx context.Context) {
 func (o *Orchestrator) cleanupExpiredSessions(ctx context.Context) {
 	now := time.Now().UTC()
 
+	o.warnExpiring(now)
+
 	o.mu.RLock()
 	var expired []string
 	for id, session := range o.sessions {
-		if now.After(session.ExpiresAt) {
+		if now.After(o.effectiveExpiry(session)) {
 			expired = append(expired, id)
 		}
 	}
@@ -319,6 +469,10 @@ func (o *Orchestrator) StartStream(ctx context.Context, sessionID string) error
 		return fmt.Errorf("failed to start stream: %s", string(respBody))
 	}
 
+	o.updateSession(sessionID, func(session *shared.Session) {
+		session.Streaming = true
+	})
+
 	return nil
 }
 
@@ -346,5 +500,9 @@ func (o *Orchestrator) StopStream(ctx context.Context, sessionID string) error {
 		return fmt.Errorf("failed to stop stream: %s", string(respBody))
 	}
 
+	o.updateSession(sessionID, func(session *shared.Session) {
+		session.Streaming = false
+	})
+
 	return nil
 }
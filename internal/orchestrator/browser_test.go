@@ -0,0 +1,41 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLaunchBrowserStopsFailedInstances(t *testing.T) {
+	tests := []struct {
+		name        string
+		failStart   bool
+		failAddress bool
+	}{
+		{name: "start fails", failStart: true},
+		{name: "address fails", failAddress: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			runtime := newFakeRuntime(t)
+			runtime.failStart = tt.failStart
+			runtime.failAddress = tt.failAddress
+			o := NewOrchestrator(runtime, Config{})
+
+			// Cleanup must not depend on the launch context.
+			cancel()
+			if _, err := o.launchBrowser(ctx, "client", o.defaultProfile(), o.defaultEgress(), false); err == nil {
+				t.Fatal("launch succeeded")
+			}
+
+			if len(runtime.instances) != 1 {
+				t.Fatalf("%d instances created, want 1", len(runtime.instances))
+			}
+			for id := range runtime.instances {
+				if !runtime.stopped(id) {
+					t.Errorf("instance %s left behind after a failed launch", id)
+				}
+			}
+		})
+	}
+}
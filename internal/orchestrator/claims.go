@@ -0,0 +1,168 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// poolClaim records that a pooled browser was handed to a session. Labels
+// are fixed when an instance is created and still describe an idle pool
+// browser, so claims are kept in PoolConfig.ClaimsFile for reconcile.
+type poolClaim struct {
+	SessionID    string    `json:"session_id"`
+	ClientID     string    `json:"client_id"`
+	APIPort      int       `json:"api_port"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxExpiresAt time.Time `json:"max_expires_at"`
+}
+
+// labels returns a copy of an instance's labels describing the session
+// the claim handed it to.
+func (c poolClaim) labels(labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[labelSessionID] = c.SessionID
+	out[labelClientID] = c.ClientID
+	out[labelState] = stateSession
+	out[labelAPIPort] = strconv.Itoa(c.APIPort)
+	out[labelCreatedAt] = c.CreatedAt.Format(time.RFC3339)
+	out[labelExpiresAt] = c.ExpiresAt.Format(time.RFC3339)
+	out[labelMaxExpiry] = c.MaxExpiresAt.Format(time.RFC3339)
+	return out
+}
+
+// claimStore keeps pool claims by instance ID in a JSON file. With no path
+// it only keeps them in memory.
+type claimStore struct {
+	path   string
+	mu     sync.Mutex
+	claims map[string]poolClaim
+}
+
+func newClaimStore(path string) *claimStore {
+	return &claimStore{path: path, claims: make(map[string]poolClaim)}
+}
+
+func (s *claimStore) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	claims := make(map[string]poolClaim)
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.claims = claims
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *claimStore) get(instanceID string) (poolClaim, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claim, ok := s.claims[instanceID]
+	return claim, ok
+}
+
+func (s *claimStore) put(instanceID string, claim poolClaim) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims[instanceID] = claim
+	s.saveLocked()
+}
+
+// update changes an existing claim, if there is one.
+func (s *claimStore) update(instanceID string, fn func(*poolClaim)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claim, ok := s.claims[instanceID]
+	if !ok {
+		return
+	}
+	fn(&claim)
+	s.claims[instanceID] = claim
+	s.saveLocked()
+}
+
+func (s *claimStore) remove(instanceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.claims[instanceID]; !ok {
+		return
+	}
+	delete(s.claims, instanceID)
+	s.saveLocked()
+}
+
+// retain drops the claims of instances not in keep.
+func (s *claimStore) retain(keep map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for id := range s.claims {
+		if !keep[id] {
+			delete(s.claims, id)
+			changed = true
+		}
+	}
+	if changed {
+		s.saveLocked()
+	}
+}
+
+// saveLocked writes the claims through a temporary file so a crash never
+// leaves a truncated file. Failures are logged: the session keeps running,
+// it just cannot be adopted after a restart.
+func (s *claimStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	if err := s.write(); err != nil {
+		log.Printf("failed to save pool claims: %v", err)
+	}
+}
+
+func (s *claimStore) write() error {
+	data, err := json.MarshalIndent(s.claims, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,94 @@
+package orchestrator
+
+import (
+	"sort"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const (
+	iOSUserAgent       = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	iPadOSUserAgent    = "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	pixelUserAgent     = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+	galaxyUserAgent    = "Mozilla/5.0 (Linux; Android 14; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+	galaxyTabUserAgent = "Mozilla/5.0 (Linux; Android 14; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+)
+
+// devicePresets are the emulated devices sessions can select by name.
+var devicePresets = map[string]shared.Device{
+	"iphone-se": {
+		Viewport:          shared.Viewport{Width: 375, Height: 667},
+		DeviceScaleFactor: 2,
+		Mobile:            true,
+		Touch:             true,
+		UserAgent:         iOSUserAgent,
+	},
+	"iphone-15": {
+		Viewport:          shared.Viewport{Width: 393, Height: 852},
+		DeviceScaleFactor: 3,
+		Mobile:            true,
+		Touch:             true,
+		UserAgent:         iOSUserAgent,
+	},
+	"iphone-15-pro-max": {
+		Viewport:          shared.Viewport{Width: 430, Height: 932},
+		DeviceScaleFactor: 3,
+		Mobile:            true,
+		Touch:             true,
+		UserAgent:         iOSUserAgent,
+	},
+	"pixel-8": {
+		Viewport:          shared.Viewport{Width: 412, Height: 915},
+		DeviceScaleFactor: 2.625,
+		Mobile:            true,
+		Touch:             true,
+		UserAgent:         pixelUserAgent,
+	},
+	"galaxy-s23": {
+		Viewport:          shared.Viewport{Width: 360, Height: 780},
+		DeviceScaleFactor: 3,
+		Mobile:            true,
+		Touch:             true,
+		UserAgent:         galaxyUserAgent,
+	},
+	"ipad-mini": {
+		Viewport:          shared.Viewport{Width: 768, Height: 1024},
+		DeviceScaleFactor: 2,
+		Mobile:            true,
+		Touch:             true,
+		UserAgent:         iPadOSUserAgent,
+	},
+	"ipad-air": {
+		Viewport:          shared.Viewport{Width: 820, Height: 1180},
+		DeviceScaleFactor: 2,
+		Mobile:            true,
+		Touch:             true,
+		UserAgent:         iPadOSUserAgent,
+	},
+	"galaxy-tab-s9": {
+		Viewport:          shared.Viewport{Width: 800, Height: 1280},
+		DeviceScaleFactor: 2,
+		Mobile:            true,
+		Touch:             true,
+		UserAgent:         galaxyTabUserAgent,
+	},
+}
+
+func devicePreset(name string) (shared.Device, bool) {
+	device, ok := devicePresets[name]
+	device.Name = name
+	return device, ok
+}
+
+// Devices lists the available device presets sorted by name.
+func Devices() []shared.Device {
+	devices := make([]shared.Device, 0, len(devicePresets))
+	for name := range devicePresets {
+		device, _ := devicePreset(name)
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Name < devices[j].Name
+	})
+	return devices
+}
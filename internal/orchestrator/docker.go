@@ -0,0 +1,367 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const browserAPIPort = 8082
+
+// egressReadyFile is created inside a container once its firewall rules are
+// in place; egressGate holds the agent back until then.
+const egressReadyFile = "/tmp/.egress-ready"
+
+// egressGate waits up to a minute for egressReadyFile before exec'ing the
+// image's own entrypoint and command, passed as arguments.
+const egressGate = `i=0
+while [ ! -e ` + egressReadyFile + ` ]; do
+  i=$((i+1))
+  if [ $i -gt 600 ]; then echo "egress rules were not applied" >&2; exit 1; fi
+  sleep 0.1
+done
+exec "$@"`
+
+type DockerRuntime struct {
+	docker      *client.Client
+	networkName string
+	// firewallImage, when set, is the image that installs egress rules from
+	// a short-lived container joined to the browser's network namespace.
+	// Otherwise the rules are installed by an exec in the browser container,
+	// whose image must then ship iptables.
+	firewallImage string
+
+	mu     sync.Mutex
+	egress map[string]EgressRules
+}
+
+func NewDockerRuntime(networkName, firewallImage string) (*DockerRuntime, error) {
+	docker, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	return &DockerRuntime{
+		docker:        docker,
+		networkName:   networkName,
+		firewallImage: firewallImage,
+		egress:        make(map[string]EgressRules),
+	}, nil
+}
+
+func (r *DockerRuntime) Create(ctx context.Context, spec BrowserSpec) (string, error) {
+	exposedPorts := nat.PortSet{
+		nat.Port(fmt.Sprintf("%d/tcp", browserAPIPort)): struct{}{},
+	}
+
+	config := &container.Config{
+		Image:        spec.Image,
+		Env:          spec.Env,
+		Labels:       withAPIPort(spec.Labels, browserAPIPort),
+		ExposedPorts: exposedPorts,
+	}
+
+	if !spec.Egress.empty() {
+		if err := r.gateEntrypoint(ctx, config); err != nil {
+			return "", err
+		}
+	}
+
+	hostConfig := &container.HostConfig{
+		AutoRemove: true,
+		Resources: container.Resources{
+			Memory:   spec.MemoryBytes,
+			NanoCPUs: spec.NanoCPUs,
+		},
+	}
+
+	var networkConfig *network.NetworkingConfig
+	if r.networkName != "" {
+		networkConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				r.networkName: {},
+			},
+		}
+	}
+
+	resp, err := r.docker.ContainerCreate(ctx, config, hostConfig, networkConfig, nil, spec.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+
+	if !spec.Egress.empty() {
+		r.mu.Lock()
+		r.egress[resp.ID] = spec.Egress
+		r.mu.Unlock()
+	}
+
+	return resp.ID, nil
+}
+
+func (r *DockerRuntime) Start(ctx context.Context, id string) error {
+	r.mu.Lock()
+	rules, hasRules := r.egress[id]
+	delete(r.egress, id)
+	r.mu.Unlock()
+
+	if err := r.docker.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	// The agent is held by egressGate until the rules are in place, so a
+	// failure here stops the container before it made any connection.
+	if hasRules {
+		if err := r.applyEgress(ctx, id, rules); err != nil {
+			r.Stop(ctx, id)
+			return fmt.Errorf("failed to apply egress policy: %w", err)
+		}
+	}
+	return nil
+}
+
+// gateEntrypoint wraps the image's entrypoint and command in egressGate.
+func (r *DockerRuntime) gateEntrypoint(ctx context.Context, config *container.Config) error {
+	image, _, err := r.docker.ImageInspectWithRaw(ctx, config.Image)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image: %w", err)
+	}
+
+	var command []string
+	if image.Config != nil {
+		command = append(command, image.Config.Entrypoint...)
+		command = append(command, image.Config.Cmd...)
+	}
+	if len(command) == 0 {
+		return fmt.Errorf("image %s has no entrypoint or command", config.Image)
+	}
+
+	config.Entrypoint = []string{"sh", "-c", egressGate, "egress-gate"}
+	config.Cmd = command
+	return nil
+}
+
+// applyEgress installs firewall rules in the container's network namespace
+// and then releases egressGate. The browser itself never holds NET_ADMIN.
+func (r *DockerRuntime) applyEgress(ctx context.Context, id string, rules EgressRules) error {
+	script := egressScript(rules)
+	if r.firewallImage != "" {
+		if err := r.runFirewall(ctx, id, script); err != nil {
+			return err
+		}
+		return r.exec(ctx, id, false, "touch "+egressReadyFile)
+	}
+	return r.exec(ctx, id, true, script+"\ntouch "+egressReadyFile)
+}
+
+// runFirewall runs script in a firewallImage container sharing the network
+// namespace of the container id.
+func (r *DockerRuntime) runFirewall(ctx context.Context, id, script string) error {
+	config := &container.Config{
+		Image:      r.firewallImage,
+		User:       "root",
+		Entrypoint: []string{"sh", "-c", script},
+	}
+	hostConfig := &container.HostConfig{
+		NetworkMode: container.NetworkMode("container:" + id),
+		CapAdd:      []string{"NET_ADMIN", "NET_RAW"},
+	}
+
+	resp, err := r.docker.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create firewall container: %w", err)
+	}
+	defer r.docker.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+
+	waitCh, errCh := r.docker.ContainerWait(ctx, resp.ID, container.WaitConditionNextExit)
+	if err := r.docker.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start firewall container: %w", err)
+	}
+
+	select {
+	case result := <-waitCh:
+		if result.StatusCode != 0 {
+			return fmt.Errorf("firewall setup exited with %d: %s", result.StatusCode, r.logs(ctx, resp.ID))
+		}
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("failed to wait for firewall container: %w", err)
+	}
+}
+
+func (r *DockerRuntime) logs(ctx context.Context, id string) string {
+	reader, err := r.docker.ContainerLogs(ctx, id, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return err.Error()
+	}
+	defer reader.Close()
+
+	var output strings.Builder
+	stdcopy.StdCopy(&output, &output, reader)
+	return strings.TrimSpace(output.String())
+}
+
+// exec runs script as root in the container id and fails on a non-zero exit.
+func (r *DockerRuntime) exec(ctx context.Context, id string, privileged bool, script string) error {
+	exec, err := r.docker.ContainerExecCreate(ctx, id, types.ExecConfig{
+		User:         "root",
+		Privileged:   privileged,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          []string{"sh", "-c", script},
+	})
+	if err != nil {
+		return err
+	}
+
+	attach, err := r.docker.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return err
+	}
+	var output strings.Builder
+	stdcopy.StdCopy(&output, &output, attach.Reader)
+	attach.Close()
+
+	inspect, err := r.docker.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return err
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("firewall setup exited with %d: %s", inspect.ExitCode, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// egressScript builds the iptables commands for rules. Loopback, replies on
+// established connections and the container's DNS servers stay reachable.
+func egressScript(rules EgressRules) string {
+	lines := []string{
+		"set -e",
+		"for t in iptables ip6tables; do",
+		"  $t -A OUTPUT -o lo -j ACCEPT",
+		"  $t -A OUTPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT",
+		"done",
+		"for ns in $(awk '/^nameserver/ {print $2}' /etc/resolv.conf); do",
+		"  case $ns in *:*) t=ip6tables ;; *) t=iptables ;; esac",
+		"  $t -A OUTPUT -d $ns -p udp --dport 53 -j ACCEPT",
+		"  $t -A OUTPUT -d $ns -p tcp --dport 53 -j ACCEPT",
+		"done",
+	}
+	for _, cidr := range rules.Allow {
+		lines = append(lines, fmt.Sprintf("%s -A OUTPUT -d %s -j ACCEPT", iptablesFor(cidr), cidr))
+	}
+	for _, cidr := range rules.Deny {
+		lines = append(lines, fmt.Sprintf("%s -A OUTPUT -d %s -j REJECT", iptablesFor(cidr), cidr))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func iptablesFor(cidr string) string {
+	if strings.Contains(cidr, ":") {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+func (r *DockerRuntime) Address(ctx context.Context, id string) (string, int, error) {
+	inspect, err := r.docker.ContainerInspect(ctx, id)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	var containerIP string
+	if r.networkName != "" && inspect.NetworkSettings.Networks[r.networkName] != nil {
+		containerIP = inspect.NetworkSettings.Networks[r.networkName].IPAddress
+	} else if inspect.NetworkSettings.IPAddress != "" {
+		containerIP = inspect.NetworkSettings.IPAddress
+	} else {
+		for _, net := range inspect.NetworkSettings.Networks {
+			if net.IPAddress != "" {
+				containerIP = net.IPAddress
+				break
+			}
+		}
+	}
+
+	return containerIP, browserAPIPort, nil
+}
+
+// Stop stops and removes the container. AutoRemove only covers containers
+// that ran, so ones that never started are removed here; a removal already
+// under way through AutoRemove is not an error.
+func (r *DockerRuntime) Stop(ctx context.Context, id string) error {
+	stopTimeout := 5
+	if err := r.docker.ContainerStop(ctx, id, container.StopOptions{Timeout: &stopTimeout}); err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	err := r.docker.ContainerRemove(ctx, id, container.RemoveOptions{Force: true})
+	if err != nil && !errdefs.IsNotFound(err) && !errdefs.IsConflict(err) {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	return nil
+}
+
+func (r *DockerRuntime) List(ctx context.Context) ([]Instance, error) {
+	containers, err := r.docker.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", labelSessionID)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	instances := make([]Instance, 0, len(containers))
+	for _, c := range containers {
+		instances = append(instances, Instance{
+			ID:     c.ID,
+			Labels: c.Labels,
+		})
+	}
+	return instances, nil
+}
+
+func (r *DockerRuntime) Stats(ctx context.Context, id string) (*shared.ResourceUsage, error) {
+	resp, err := r.docker.ContainerStats(ctx, id, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var stats types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+
+	usage := &shared.ResourceUsage{
+		MemoryBytes:      stats.MemoryStats.Usage,
+		MemoryLimitBytes: stats.MemoryStats.Limit,
+	}
+
+	// Same calculation as `docker stats`.
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta > 0 && systemDelta > 0 {
+		cpus := float64(stats.CPUStats.OnlineCPUs)
+		if cpus == 0 {
+			cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+		}
+		usage.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	for _, net := range stats.Networks {
+		usage.NetworkRxBytes += net.RxBytes
+		usage.NetworkTxBytes += net.TxBytes
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,190 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+// EgressConfig controls where session browsers may connect.
+//
+// DenyCIDRs only cover connections the browser makes itself. Traffic sent
+// through a proxy leaves from the proxy, which must enforce its own policy;
+// here only the requested URL is checked, by CheckEgress.
+type EgressConfig struct {
+	// DefaultProxy is the upstream HTTP or SOCKS proxy used when a session
+	// does not choose one. Empty means direct connections.
+	DefaultProxy string
+	// Proxies lists the additional proxies sessions may select.
+	Proxies []string
+	// DenyCIDRs are blocked for every session. Nil defaults to
+	// shared.DefaultDeniedCIDRs; an empty list denies nothing.
+	DenyCIDRs []string
+}
+
+func (c EgressConfig) withDefaults() EgressConfig {
+	if c.DenyCIDRs == nil {
+		c.DenyCIDRs = shared.DefaultDeniedCIDRs
+	}
+	return c
+}
+
+func (c EgressConfig) Validate() error {
+	if _, err := shared.ParseCIDRs(c.DenyCIDRs); err != nil {
+		return err
+	}
+	for _, proxy := range append([]string{c.DefaultProxy}, c.Proxies...) {
+		if proxy == "" {
+			continue
+		}
+		if _, err := parseProxy(proxy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EgressRules are the network-level rules a runtime enforces for an
+// instance: Allow ranges are accepted before Deny ranges are rejected.
+type EgressRules struct {
+	Allow []string
+	Deny  []string
+}
+
+func (r EgressRules) empty() bool {
+	return len(r.Deny) == 0
+}
+
+func parseProxy(proxy string) (*url.URL, error) {
+	parsed, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: unsupported scheme", proxy)
+	}
+	if parsed.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy %q: host is required", proxy)
+	}
+	return parsed, nil
+}
+
+func (o *Orchestrator) defaultEgress() shared.EgressPolicy {
+	return shared.EgressPolicy{
+		Proxy:     o.egressCfg.DefaultProxy,
+		DenyCIDRs: o.egressCfg.DenyCIDRs,
+	}
+}
+
+// resolveEgress picks the session's proxy from the allow-list.
+func (o *Orchestrator) resolveEgress(proxy string) (shared.EgressPolicy, error) {
+	policy := o.defaultEgress()
+	if proxy == "" || proxy == policy.Proxy {
+		return policy, nil
+	}
+
+	for _, allowed := range o.egressCfg.Proxies {
+		if proxy == allowed {
+			policy.Proxy = proxy
+			return policy, nil
+		}
+	}
+	return shared.EgressPolicy{}, &shared.ValidationError{Field: "proxy", Message: "proxy is not allowed"}
+}
+
+func sameEgress(a, b shared.EgressPolicy) bool {
+	if a.Proxy != b.Proxy || len(a.DenyCIDRs) != len(b.DenyCIDRs) {
+		return false
+	}
+	for i := range a.DenyCIDRs {
+		if a.DenyCIDRs[i] != b.DenyCIDRs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckEgress rejects URLs whose host resolves into the session's denied
+// ranges. The runtime enforces the same ranges at the network level, which
+// also covers redirects and subresources.
+func (o *Orchestrator) CheckEgress(ctx context.Context, sessionID, rawURL string) error {
+	policy, ok := o.SessionEgress(sessionID)
+	if !ok {
+		return fmt.Errorf("session not found")
+	}
+
+	denied, err := shared.ParseCIDRs(policy.DenyCIDRs)
+	if err != nil {
+		return err
+	}
+	return shared.ValidateEgress(ctx, rawURL, denied)
+}
+
+// SessionEgress returns the egress policy the session runs under.
+func (o *Orchestrator) SessionEgress(sessionID string) (shared.EgressPolicy, bool) {
+	session, ok := o.SessionSnapshot(sessionID)
+	if !ok {
+		return shared.EgressPolicy{}, false
+	}
+	if session.Egress != nil {
+		return *session.Egress, true
+	}
+	return o.defaultEgress(), true
+}
+
+// egressEnv passes the proxy to the browser agent. NO_PROXY keeps the
+// agent's own loopback API reachable.
+func egressEnv(policy shared.EgressPolicy) []string {
+	if policy.Proxy == "" {
+		return nil
+	}
+	return []string{
+		"PROXY_SERVER=" + policy.Proxy,
+		"HTTP_PROXY=" + policy.Proxy,
+		"HTTPS_PROXY=" + policy.Proxy,
+		"ALL_PROXY=" + policy.Proxy,
+		"NO_PROXY=localhost,127.0.0.1,::1",
+	}
+}
+
+// egressRules turns a policy into network rules. The proxy's own addresses
+// are allowed even when they sit inside a denied range; what the proxy
+// connects to on the browser's behalf is outside these rules.
+func egressRules(ctx context.Context, policy shared.EgressPolicy) (EgressRules, error) {
+	rules := EgressRules{Deny: policy.DenyCIDRs}
+	if policy.Proxy == "" || len(policy.DenyCIDRs) == 0 {
+		return rules, nil
+	}
+
+	proxyURL, err := parseProxy(policy.Proxy)
+	if err != nil {
+		return EgressRules{}, err
+	}
+
+	host := proxyURL.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		rules.Allow = append(rules.Allow, hostCIDR(ip))
+		return rules, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return EgressRules{}, fmt.Errorf("failed to resolve proxy host: %w", err)
+	}
+	for _, addr := range addrs {
+		rules.Allow = append(rules.Allow, hostCIDR(addr.IP))
+	}
+	return rules, nil
+}
+
+func hostCIDR(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
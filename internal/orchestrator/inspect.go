@@ -0,0 +1,60 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+// ListSessions returns a snapshot of all active sessions, oldest first.
+func (o *Orchestrator) ListSessions() []shared.Session {
+	o.mu.RLock()
+	sessions := make([]shared.Session, 0, len(o.sessions))
+	for _, session := range o.sessions {
+		sessions = append(sessions, *session)
+	}
+	o.mu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions
+}
+
+// SessionSnapshot returns a copy of the session that is safe to read while
+// the session keeps changing.
+func (o *Orchestrator) SessionSnapshot(sessionID string) (shared.Session, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	session, ok := o.sessions[sessionID]
+	if !ok {
+		return shared.Session{}, false
+	}
+	return *session, true
+}
+
+// Viewers reports how many clients are connected to the session stream.
+func (o *Orchestrator) Viewers(sessionID string) int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.watchers[sessionID])
+}
+
+func (o *Orchestrator) SessionStats(ctx context.Context, sessionID string) (*shared.ResourceUsage, error) {
+	session, ok := o.GetSession(sessionID)
+	if !ok {
+		return nil, fmt.Errorf("session not found")
+	}
+	return o.runtime.Stats(ctx, session.ContainerID)
+}
+
+func (o *Orchestrator) updateSession(sessionID string, update func(*shared.Session)) {
+	o.mu.Lock()
+	if session, ok := o.sessions[sessionID]; ok {
+		update(session)
+	}
+	o.mu.Unlock()
+}
@@ -0,0 +1,99 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const (
+	defaultMaxLifetime   = 2 * time.Hour
+	defaultExpiryWarning = 1 * time.Minute
+)
+
+// SessionConfig controls how long sessions live.
+type SessionConfig struct {
+	// Timeout is the initial lifetime and the default extension step.
+	Timeout time.Duration `json:"timeout"`
+	// IdleTimeout ends a session after this long without input. Zero
+	// disables idle expiry.
+	IdleTimeout time.Duration `json:"idle_timeout"`
+	// MaxLifetime bounds how far a session can be extended past creation.
+	MaxLifetime time.Duration `json:"max_lifetime"`
+	// ExpiryWarning is how long before expiry connected viewers are warned.
+	ExpiryWarning time.Duration `json:"expiry_warning"`
+}
+
+func (c SessionConfig) withDefaults() SessionConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = sessionTimeout
+	}
+	if c.MaxLifetime <= 0 {
+		c.MaxLifetime = defaultMaxLifetime
+	}
+	if c.MaxLifetime < c.Timeout {
+		c.MaxLifetime = c.Timeout
+	}
+	if c.ExpiryWarning <= 0 {
+		c.ExpiryWarning = defaultExpiryWarning
+	}
+	return c
+}
+
+// ExtendSession pushes the session's expiry out by d, or by the configured
+// timeout when d is zero, without exceeding its maximum lifetime.
+func (o *Orchestrator) ExtendSession(sessionID string, d time.Duration) (*shared.Session, error) {
+	if d <= 0 {
+		d = o.sessionCfg.Timeout
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	session, ok := o.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	now := time.Now().UTC()
+	base := session.ExpiresAt
+	if base.Before(now) {
+		base = now
+	}
+	expiresAt := base.Add(d)
+	if expiresAt.After(session.MaxExpiresAt) {
+		expiresAt = session.MaxExpiresAt
+	}
+
+	session.ExpiresAt = expiresAt
+	session.LastActivityAt = now
+	o.claims.update(session.ContainerID, func(claim *poolClaim) {
+		claim.ExpiresAt = expiresAt
+	})
+
+	snapshot := *session
+	return &snapshot, nil
+}
+
+// Touch records user activity, postponing idle expiry.
+func (o *Orchestrator) Touch(sessionID string) {
+	o.mu.Lock()
+	if session, ok := o.sessions[sessionID]; ok {
+		session.LastActivityAt = time.Now().UTC()
+	}
+	o.mu.Unlock()
+}
+
+// effectiveExpiry is the earlier of the session deadline and its idle
+// deadline. Callers must hold o.mu.
+func (o *Orchestrator) effectiveExpiry(session *shared.Session) time.Time {
+	expiresAt := session.ExpiresAt
+	if o.sessionCfg.IdleTimeout > 0 {
+		idleAt := session.LastActivityAt.Add(o.sessionCfg.IdleTimeout)
+		if idleAt.Before(expiresAt) {
+			expiresAt = idleAt
+		}
+	}
+	return expiresAt
+}
@@ -0,0 +1,87 @@
+package orchestrator
+
+import "time"
+
+const (
+	NoticeExpiring = "session_expiring"
+	NoticeExpired  = "session_expired"
+)
+
+// SessionNotice is pushed to viewers connected to a session.
+type SessionNotice struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"session_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Subscribe returns a channel of notices for the session and a function to
+// unsubscribe. The channel is closed when the session is destroyed.
+func (o *Orchestrator) Subscribe(sessionID string) (<-chan SessionNotice, func()) {
+	ch := make(chan SessionNotice, 4)
+
+	o.mu.Lock()
+	if _, ok := o.sessions[sessionID]; !ok {
+		o.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if o.watchers[sessionID] == nil {
+		o.watchers[sessionID] = make(map[chan SessionNotice]struct{})
+	}
+	o.watchers[sessionID][ch] = struct{}{}
+	o.mu.Unlock()
+
+	unsubscribe := func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if _, ok := o.watchers[sessionID][ch]; ok {
+			delete(o.watchers[sessionID], ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// notify delivers a notice without blocking; slow viewers miss it. Callers
+// must hold o.mu.
+func (o *Orchestrator) notify(notice SessionNotice) {
+	for ch := range o.watchers[notice.SessionID] {
+		select {
+		case ch <- notice:
+		default:
+		}
+	}
+}
+
+// closeWatchers notifies and disconnects all viewers of a session. Callers
+// must hold o.mu.
+func (o *Orchestrator) closeWatchers(sessionID string, notice SessionNotice) {
+	o.notify(notice)
+	for ch := range o.watchers[sessionID] {
+		close(ch)
+	}
+	delete(o.watchers, sessionID)
+}
+
+// warnExpiring notifies viewers of sessions that are about to expire. Each
+// deadline is announced once; extending the session re-arms the warning.
+func (o *Orchestrator) warnExpiring(now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for id, session := range o.sessions {
+		expiresAt := o.effectiveExpiry(session)
+		if now.Add(o.sessionCfg.ExpiryWarning).Before(expiresAt) || !now.Before(expiresAt) {
+			continue
+		}
+		if o.warned[id].Equal(expiresAt) {
+			continue
+		}
+		o.warned[id] = expiresAt
+		o.notify(SessionNotice{
+			Type:      NoticeExpiring,
+			SessionID: id,
+			ExpiresAt: expiresAt,
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const (
+	poolRefillInterval = 10 * time.Second
+	poolMaxIdleAge     = 30 * time.Minute
+)
+
+// PoolConfig sizes the warm pool of pre-started browsers.
+type PoolConfig struct {
+	// Size is the number of ready browsers to keep on standby. Zero
+	// disables the pool.
+	Size int `json:"size"`
+	// MinIdle triggers a refill once fewer browsers than this are idle.
+	// Defaults to Size.
+	MinIdle int `json:"min_idle"`
+	// MaxTotal caps pooled plus in-use browsers; the pool does not refill
+	// beyond it. Zero means no cap.
+	MaxTotal int `json:"max_total"`
+	// ClaimsFile records which pooled browsers were handed to sessions, so
+	// they are adopted rather than stopped after a restart. Empty keeps
+	// the record in memory only.
+	ClaimsFile string `json:"-"`
+}
+
+type PoolStatus struct {
+	PoolConfig
+	Idle     int `json:"idle"`
+	Starting int `json:"starting"`
+	InUse    int `json:"in_use"`
+}
+
+type warmPool struct {
+	cfg      PoolConfig
+	mu       sync.Mutex
+	idle     []*shared.Session
+	starting int
+	closed   bool
+	refill   chan struct{}
+}
+
+func newWarmPool(cfg PoolConfig) *warmPool {
+	if cfg.MinIdle <= 0 || cfg.MinIdle > cfg.Size {
+		cfg.MinIdle = cfg.Size
+	}
+	return &warmPool{
+		cfg:    cfg,
+		refill: make(chan struct{}, 1),
+	}
+}
+
+func (p *warmPool) requestRefill() {
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+// takePooled hands out an idle browser, or returns nil when the pool is
+// empty. Browsers that no longer pass health checks are discarded.
+func (o *Orchestrator) takePooled(ctx context.Context) *shared.Session {
+	p := o.pool
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		session := p.idle[0]
+		p.idle = p.idle[1:]
+		lowWater := len(p.idle) < p.cfg.MinIdle
+		p.mu.Unlock()
+
+		if lowWater {
+			p.requestRefill()
+		}
+
+		if err := o.checkHealth(ctx, session); err != nil {
+			log.Printf("discarding unhealthy pooled browser %s: %v", session.SessionID, err)
+			o.runtime.Stop(ctx, session.ContainerID)
+			continue
+		}
+		return session
+	}
+}
+
+func (o *Orchestrator) poolLoop(ctx context.Context) {
+	if o.pool.cfg.Size <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(poolRefillInterval)
+	defer ticker.Stop()
+
+	o.fillPool(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.stopChan:
+			return
+		case <-o.pool.refill:
+			o.fillPool(ctx)
+		case <-ticker.C:
+			o.recycleIdle(ctx)
+			o.fillPool(ctx)
+		}
+	}
+}
+
+// fillPool starts browsers one at a time until the pool reaches its target
+// size or the total cap.
+func (o *Orchestrator) fillPool(ctx context.Context) {
+	p := o.pool
+	for {
+		p.mu.Lock()
+		pooled := len(p.idle) + p.starting
+		total := pooled + o.sessionCount()
+		if p.closed || pooled >= p.cfg.Size || (p.cfg.MaxTotal > 0 && total >= p.cfg.MaxTotal) {
+			p.mu.Unlock()
+			return
+		}
+		p.starting++
+		p.mu.Unlock()
+
+		session, err := o.launchBrowser(ctx, "", o.defaultProfile(), o.defaultEgress(), true)
+
+		if err != nil {
+			p.mu.Lock()
+			p.starting--
+			p.mu.Unlock()
+			log.Printf("failed to start pooled browser: %v", err)
+			return
+		}
+
+		p.mu.Lock()
+		p.starting--
+		closed := p.closed
+		if !closed {
+			p.idle = append(p.idle, session)
+		}
+		p.mu.Unlock()
+
+		if closed {
+			// The pool closes on shutdown, when ctx is usually done too.
+			stopCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			o.runtime.Stop(stopCtx, session.ContainerID)
+			cancel()
+			return
+		}
+	}
+}
+
+// recycleIdle stops pooled browsers that have been waiting longer than
+// poolMaxIdleAge.
+func (o *Orchestrator) recycleIdle(ctx context.Context) {
+	p := o.pool
+	cutoff := time.Now().UTC().Add(-poolMaxIdleAge)
+
+	p.mu.Lock()
+	var stale []*shared.Session
+	fresh := p.idle[:0]
+	for _, session := range p.idle {
+		if session.CreatedAt.Before(cutoff) {
+			stale = append(stale, session)
+		} else {
+			fresh = append(fresh, session)
+		}
+	}
+	p.idle = fresh
+	p.mu.Unlock()
+
+	for _, session := range stale {
+		log.Printf("recycling idle pooled browser %s", session.SessionID)
+		o.runtime.Stop(ctx, session.ContainerID)
+	}
+}
+
+func (o *Orchestrator) drainPool() {
+	p := o.pool
+
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, session := range idle {
+		if err := o.runtime.Stop(ctx, session.ContainerID); err != nil {
+			log.Printf("failed to stop pooled browser %s: %v", session.ContainerID, err)
+		}
+	}
+}
+
+func (o *Orchestrator) sessionCount() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.sessions)
+}
+
+func (o *Orchestrator) PoolStatus() PoolStatus {
+	inUse := o.sessionCount()
+
+	p := o.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStatus{
+		PoolConfig: p.cfg,
+		Idle:       len(p.idle),
+		Starting:   p.starting,
+		InUse:      inUse,
+	}
+}
@@ -0,0 +1,120 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFillPool(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       PoolConfig
+		sessions  int
+		failStart bool
+		wantIdle  int
+	}{
+		{name: "disabled", cfg: PoolConfig{}, wantIdle: 0},
+		{name: "fills to size", cfg: PoolConfig{Size: 3}, wantIdle: 3},
+		{name: "total cap", cfg: PoolConfig{Size: 3, MaxTotal: 2}, wantIdle: 2},
+		{name: "total cap counts sessions", cfg: PoolConfig{Size: 3, MaxTotal: 3}, sessions: 2, wantIdle: 1},
+		{name: "launch failure", cfg: PoolConfig{Size: 2}, failStart: true, wantIdle: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			runtime := newFakeRuntime(t)
+			o := NewOrchestrator(runtime, Config{Pool: tt.cfg})
+			for i := 0; i < tt.sessions; i++ {
+				if _, err := o.CreateSession(ctx, SessionOptions{ClientID: "client"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			runtime.failStart = tt.failStart
+			o.fillPool(ctx)
+
+			status := o.PoolStatus()
+			if status.Idle != tt.wantIdle || status.Starting != 0 {
+				t.Errorf("idle %d, starting %d; want %d, 0", status.Idle, status.Starting, tt.wantIdle)
+			}
+			if running := len(runtime.running()); running != tt.wantIdle+tt.sessions {
+				t.Errorf("%d browsers running, want %d", running, tt.wantIdle+tt.sessions)
+			}
+		})
+	}
+}
+
+func TestPoolRefillsAfterHandOut(t *testing.T) {
+	ctx := context.Background()
+	runtime := newFakeRuntime(t)
+	o := NewOrchestrator(runtime, Config{Pool: PoolConfig{Size: 2}})
+	o.fillPool(ctx)
+
+	if _, err := o.CreateSession(ctx, SessionOptions{ClientID: "client"}); err != nil {
+		t.Fatal(err)
+	}
+	if idle := o.PoolStatus().Idle; idle != 1 {
+		t.Fatalf("idle %d after a hand-out, want 1", idle)
+	}
+	select {
+	case <-o.pool.refill:
+	default:
+		t.Fatal("dropping below MinIdle did not request a refill")
+	}
+
+	o.fillPool(ctx)
+	if status := o.PoolStatus(); status.Idle != 2 || status.InUse != 1 {
+		t.Errorf("idle %d, in use %d after refill; want 2, 1", status.Idle, status.InUse)
+	}
+}
+
+func TestRecycleIdle(t *testing.T) {
+	ctx := context.Background()
+	runtime := newFakeRuntime(t)
+	o := NewOrchestrator(runtime, Config{Pool: PoolConfig{Size: 2}})
+	o.fillPool(ctx)
+
+	stale := o.pool.idle[0]
+	stale.CreatedAt = time.Now().UTC().Add(-poolMaxIdleAge - time.Minute)
+	o.recycleIdle(ctx)
+
+	if !runtime.stopped(stale.ContainerID) {
+		t.Error("stale pooled browser was not stopped")
+	}
+	if idle := o.PoolStatus().Idle; idle != 1 {
+		t.Errorf("idle %d after recycling, want 1", idle)
+	}
+}
+
+func TestDrainPool(t *testing.T) {
+	ctx := context.Background()
+	runtime := newFakeRuntime(t)
+	o := NewOrchestrator(runtime, Config{Pool: PoolConfig{Size: 2}})
+	o.fillPool(ctx)
+	session, err := o.CreateSession(ctx, SessionOptions{ClientID: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle := append([]string(nil), runtime.running()...)
+
+	o.drainPool()
+
+	for _, id := range idle {
+		if id != session.ContainerID && !runtime.stopped(id) {
+			t.Errorf("pooled browser %s was not stopped", id)
+		}
+	}
+	if runtime.stopped(session.ContainerID) {
+		t.Error("draining stopped a browser in use")
+	}
+
+	// A closed pool does not refill.
+	o.fillPool(ctx)
+	if status := o.PoolStatus(); status.Idle != 0 || status.Starting != 0 {
+		t.Errorf("idle %d, starting %d after drain; want 0, 0", status.Idle, status.Starting)
+	}
+	if running := runtime.running(); len(running) != 1 {
+		t.Errorf("%d browsers running after drain, want only the session's", len(running))
+	}
+}
@@ -0,0 +1,191 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+// ProcessRuntime runs the browser agent as a child process listening on a
+// free loopback port. It ignores the image and resource limits in the spec
+// and cannot enforce egress rules at the network level; URL checks in
+// CheckEgress still apply.
+type ProcessRuntime struct {
+	command string
+	args    []string
+
+	warnEgress sync.Once
+
+	mu        sync.Mutex
+	processes map[string]*browserProcess
+	nextID    int
+}
+
+type browserProcess struct {
+	cmd    *exec.Cmd
+	port   int
+	labels map[string]string
+	done   chan struct{}
+}
+
+func NewProcessRuntime(command string, args ...string) *ProcessRuntime {
+	return &ProcessRuntime{
+		command:   command,
+		args:      args,
+		processes: make(map[string]*browserProcess),
+	}
+}
+
+func (r *ProcessRuntime) Create(ctx context.Context, spec BrowserSpec) (string, error) {
+	if !spec.Egress.empty() {
+		r.warnEgress.Do(func() {
+			log.Printf("process runtime cannot enforce network egress rules; only URL validation applies")
+		})
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate port: %w", err)
+	}
+
+	cmd := exec.Command(r.command, r.args...)
+	cmd.Env = append(processEnv(), spec.Env...)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("API_PORT=%d", port))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	id := fmt.Sprintf("%s-%d", spec.Name, r.nextID)
+	r.processes[id] = &browserProcess{
+		cmd:    cmd,
+		port:   port,
+		labels: withAPIPort(spec.Labels, port),
+		done:   make(chan struct{}),
+	}
+
+	return id, nil
+}
+
+// processEnv is the part of the node's environment the agent inherits. The
+// rest, which holds storage and signing credentials, is withheld.
+func processEnv() []string {
+	var env []string
+	for _, key := range []string{"PATH", "HOME"} {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+func (r *ProcessRuntime) Start(ctx context.Context, id string) error {
+	proc, err := r.get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := proc.cmd.Start(); err != nil {
+		r.remove(id)
+		return fmt.Errorf("failed to start browser process: %w", err)
+	}
+
+	go func() {
+		if err := proc.cmd.Wait(); err != nil {
+			log.Printf("browser process %s exited: %v", id, err)
+		}
+		close(proc.done)
+		r.remove(id)
+	}()
+
+	return nil
+}
+
+func (r *ProcessRuntime) Address(ctx context.Context, id string) (string, int, error) {
+	proc, err := r.get(id)
+	if err != nil {
+		return "", 0, err
+	}
+	return "127.0.0.1", proc.port, nil
+}
+
+func (r *ProcessRuntime) Stop(ctx context.Context, id string) error {
+	proc, err := r.get(id)
+	if err != nil {
+		return err
+	}
+
+	if proc.cmd.Process == nil {
+		r.remove(id)
+		return nil
+	}
+
+	if err := proc.cmd.Process.Signal(os.Interrupt); err != nil {
+		return proc.cmd.Process.Kill()
+	}
+
+	select {
+	case <-proc.done:
+		return nil
+	case <-time.After(5 * time.Second):
+		return proc.cmd.Process.Kill()
+	}
+}
+
+// List returns the processes started by this runtime instance; processes left
+// behind by a previous capture-node run are not rediscovered.
+func (r *ProcessRuntime) List(ctx context.Context) ([]Instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	instances := make([]Instance, 0, len(r.processes))
+	for id, proc := range r.processes {
+		instances = append(instances, Instance{
+			ID:     id,
+			Labels: proc.labels,
+		})
+	}
+	return instances, nil
+}
+
+func (r *ProcessRuntime) Stats(ctx context.Context, id string) (*shared.ResourceUsage, error) {
+	if _, err := r.get(id); err != nil {
+		return nil, err
+	}
+	return nil, ErrStatsUnavailable
+}
+
+func (r *ProcessRuntime) get(id string) (*browserProcess, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	proc, ok := r.processes[id]
+	if !ok {
+		return nil, fmt.Errorf("browser process %s not found", id)
+	}
+	return proc, nil
+}
+
+func (r *ProcessRuntime) remove(id string) {
+	r.mu.Lock()
+	delete(r.processes, id)
+	r.mu.Unlock()
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
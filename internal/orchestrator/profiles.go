@@ -0,0 +1,251 @@
+package orchestrator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const (
+	defaultProfileName = "default"
+	defaultMemoryMB    = 2048
+	defaultCPUs        = 2.0
+	maxUserAgentLength = 512
+)
+
+var localeRegex = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// ProfileConfig lists the browser profiles and limits sessions may choose
+// from.
+type ProfileConfig struct {
+	// Profiles are named presets; "default" is used when a request names
+	// none. A built-in default is added when missing.
+	Profiles map[string]shared.BrowserProfile
+	// Images is the allow-list of browser images. The default profile's
+	// image is always allowed.
+	Images []string
+	// MaxMemoryMB and MaxCPUs bound per-session resource requests.
+	MaxMemoryMB int
+	MaxCPUs     float64
+}
+
+func (c ProfileConfig) withDefaults() ProfileConfig {
+	profiles := make(map[string]shared.BrowserProfile, len(c.Profiles)+1)
+	for name, profile := range c.Profiles {
+		profile.Name = name
+		profiles[name] = profile
+	}
+	if _, ok := profiles[defaultProfileName]; !ok {
+		profiles[defaultProfileName] = shared.BrowserProfile{Name: defaultProfileName}
+	}
+	for name, profile := range profiles {
+		if profile.Image == "" {
+			profile.Image = browserImage
+		}
+		if profile.MemoryMB == 0 {
+			profile.MemoryMB = defaultMemoryMB
+		}
+		if profile.CPUs == 0 {
+			profile.CPUs = defaultCPUs
+		}
+		profiles[name] = profile
+	}
+	c.Profiles = profiles
+
+	if c.MaxMemoryMB <= 0 {
+		c.MaxMemoryMB = defaultMemoryMB
+	}
+	if c.MaxCPUs <= 0 {
+		c.MaxCPUs = defaultCPUs
+	}
+	return c
+}
+
+// ProfileRequest selects a named profile and optionally overrides parts of
+// it. Zero values keep the profile's setting.
+type ProfileRequest struct {
+	Profile           string
+	Device            string
+	Image             string
+	Viewport          *shared.Viewport
+	DeviceScaleFactor float64
+	Locale            string
+	Timezone          string
+	UserAgent         string
+	MemoryMB          int
+	CPUs              float64
+}
+
+func (o *Orchestrator) defaultProfile() shared.BrowserProfile {
+	return o.profileCfg.Profiles[defaultProfileName]
+}
+
+// ResolveProfile merges the request over its base profile and validates the
+// result against the allow-list and resource limits.
+func (o *Orchestrator) ResolveProfile(req ProfileRequest) (shared.BrowserProfile, error) {
+	name := req.Profile
+	if name == "" {
+		name = defaultProfileName
+	}
+
+	profile, ok := o.profileCfg.Profiles[name]
+	if !ok {
+		return shared.BrowserProfile{}, &shared.ValidationError{Field: "profile", Message: fmt.Sprintf("unknown profile %q", name)}
+	}
+	if profile.Viewport != nil {
+		viewport := *profile.Viewport
+		profile.Viewport = &viewport
+	}
+
+	if req.Device != "" {
+		device, ok := devicePreset(req.Device)
+		if !ok {
+			return shared.BrowserProfile{}, &shared.ValidationError{Field: "device", Message: fmt.Sprintf("unknown device %q", req.Device)}
+		}
+		viewport := device.Viewport
+		profile.Device = device.Name
+		profile.Viewport = &viewport
+		profile.DeviceScaleFactor = device.DeviceScaleFactor
+		profile.Mobile = device.Mobile
+		profile.Touch = device.Touch
+		profile.UserAgent = device.UserAgent
+	}
+
+	if req.Image != "" {
+		profile.Image = req.Image
+	}
+	if req.Viewport != nil {
+		viewport := *req.Viewport
+		profile.Viewport = &viewport
+	}
+	if req.DeviceScaleFactor != 0 {
+		profile.DeviceScaleFactor = req.DeviceScaleFactor
+	}
+	if req.Locale != "" {
+		profile.Locale = req.Locale
+	}
+	if req.Timezone != "" {
+		profile.Timezone = req.Timezone
+	}
+	if req.UserAgent != "" {
+		profile.UserAgent = req.UserAgent
+	}
+	if req.MemoryMB != 0 {
+		profile.MemoryMB = req.MemoryMB
+	}
+	if req.CPUs != 0 {
+		profile.CPUs = req.CPUs
+	}
+
+	if err := o.validateProfile(profile); err != nil {
+		return shared.BrowserProfile{}, err
+	}
+	return profile, nil
+}
+
+func (o *Orchestrator) validateProfile(p shared.BrowserProfile) error {
+	if !o.imageAllowed(p.Image) {
+		return &shared.ValidationError{Field: "image", Message: fmt.Sprintf("image %q is not allowed", p.Image)}
+	}
+
+	if p.Viewport != nil {
+		if p.Viewport.Width < 200 || p.Viewport.Width > 7680 || p.Viewport.Height < 200 || p.Viewport.Height > 4320 {
+			return &shared.ValidationError{Field: "viewport", Message: "must be between 200x200 and 7680x4320"}
+		}
+	}
+
+	if p.DeviceScaleFactor != 0 && (p.DeviceScaleFactor < 0.5 || p.DeviceScaleFactor > 4) {
+		return &shared.ValidationError{Field: "device_scale_factor", Message: "must be between 0.5 and 4"}
+	}
+
+	if p.Locale != "" && !localeRegex.MatchString(p.Locale) {
+		return &shared.ValidationError{Field: "locale", Message: "must be a BCP 47 language tag"}
+	}
+
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return &shared.ValidationError{Field: "timezone", Message: "must be an IANA time zone name"}
+		}
+	}
+
+	if len(p.UserAgent) > maxUserAgentLength {
+		return &shared.ValidationError{Field: "user_agent", Message: fmt.Sprintf("must be at most %d characters", maxUserAgentLength)}
+	}
+	for _, r := range p.UserAgent {
+		if unicode.IsControl(r) {
+			return &shared.ValidationError{Field: "user_agent", Message: "must not contain control characters"}
+		}
+	}
+
+	if p.MemoryMB < 256 || p.MemoryMB > o.profileCfg.MaxMemoryMB {
+		return &shared.ValidationError{Field: "memory_mb", Message: fmt.Sprintf("must be between 256 and %d", o.profileCfg.MaxMemoryMB)}
+	}
+
+	if p.CPUs < 0.25 || p.CPUs > o.profileCfg.MaxCPUs {
+		return &shared.ValidationError{Field: "cpus", Message: fmt.Sprintf("must be between 0.25 and %g", o.profileCfg.MaxCPUs)}
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) imageAllowed(image string) bool {
+	if image == o.defaultProfile().Image {
+		return true
+	}
+	for _, allowed := range o.profileCfg.Images {
+		if image == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// profileEnv translates a profile into the browser agent's environment.
+func profileEnv(p shared.BrowserProfile) []string {
+	var env []string
+	if p.Viewport != nil {
+		env = append(env,
+			"VIEWPORT_WIDTH="+strconv.Itoa(p.Viewport.Width),
+			"VIEWPORT_HEIGHT="+strconv.Itoa(p.Viewport.Height),
+		)
+	}
+	if p.DeviceScaleFactor != 0 {
+		env = append(env, "DEVICE_SCALE_FACTOR="+strconv.FormatFloat(p.DeviceScaleFactor, 'f', -1, 64))
+	}
+	if p.Device != "" {
+		env = append(env, "DEVICE_NAME="+p.Device)
+	}
+	if p.Mobile {
+		env = append(env, "IS_MOBILE=true")
+	}
+	if p.Touch {
+		env = append(env, "HAS_TOUCH=true")
+	}
+	if p.Locale != "" {
+		env = append(env, "LOCALE="+p.Locale, "LANG="+p.Locale)
+	}
+	if p.Timezone != "" {
+		env = append(env, "TIMEZONE="+p.Timezone, "TZ="+p.Timezone)
+	}
+	if p.UserAgent != "" {
+		env = append(env, "USER_AGENT="+p.UserAgent)
+	}
+	return env
+}
+
+// sameProfile reports whether two profiles launch identical browsers.
+func sameProfile(a, b shared.BrowserProfile) bool {
+	if (a.Viewport == nil) != (b.Viewport == nil) {
+		return false
+	}
+	if a.Viewport != nil && *a.Viewport != *b.Viewport {
+		return false
+	}
+	a.Name, b.Name = "", ""
+	a.Viewport, b.Viewport = nil, nil
+	return a == b
+}
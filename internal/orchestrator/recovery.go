@@ -0,0 +1,220 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const (
+	labelSessionID = "ai.intrace.session-id"
+	labelClientID  = "ai.intrace.client-id"
+	labelProfile   = "ai.intrace.profile"
+	labelEgress    = "ai.intrace.egress"
+	labelCreatedAt = "ai.intrace.created-at"
+	labelExpiresAt = "ai.intrace.expires-at"
+	labelMaxExpiry = "ai.intrace.max-expires-at"
+	labelAPIPort   = "ai.intrace.api-port"
+	labelState     = "ai.intrace.state"
+)
+
+// Values of labelState. Labels are fixed at creation, so a pooled browser
+// keeps statePooled after it is handed out to a session; the hand-out is
+// recorded as a poolClaim instead.
+const (
+	stateSession = "session"
+	statePooled  = "pooled"
+)
+
+func sessionLabels(sessionID, clientID, state string, profile shared.BrowserProfile, egress shared.EgressPolicy, createdAt, expiresAt, maxExpiresAt time.Time) map[string]string {
+	profileJSON, _ := json.Marshal(profile)
+	egressJSON, _ := json.Marshal(egress)
+	return map[string]string{
+		labelSessionID: sessionID,
+		labelClientID:  clientID,
+		labelState:     state,
+		labelProfile:   string(profileJSON),
+		labelEgress:    string(egressJSON),
+		labelCreatedAt: createdAt.Format(time.RFC3339),
+		labelExpiresAt: expiresAt.Format(time.RFC3339),
+		labelMaxExpiry: maxExpiresAt.Format(time.RFC3339),
+	}
+}
+
+// withAPIPort returns a copy of labels that also records the agent's API
+// port. Runtimes call it because only they know which port is used.
+func withAPIPort(labels map[string]string, port int) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[labelAPIPort] = strconv.Itoa(port)
+	return out
+}
+
+// reconcile rebuilds the session map from labeled browser instances left
+// behind by a previous run. Live sessions are adopted, including pooled
+// browsers claimed by a session; expired or unhealthy sessions and idle
+// pooled browsers are stopped.
+func (o *Orchestrator) reconcile(ctx context.Context) {
+	if err := o.claims.load(); err != nil {
+		log.Printf("failed to load pool claims: %v", err)
+	}
+
+	instances, err := o.runtime.List(ctx)
+	if err != nil {
+		log.Printf("failed to list browser instances: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	adopted := make(map[string]bool)
+	for _, inst := range instances {
+		if pooledInstance(inst) {
+			claim, ok := o.claims.get(inst.ID)
+			if !ok {
+				log.Printf("stopping pooled browser instance %s", inst.ID)
+				o.runtime.Stop(ctx, inst.ID)
+				continue
+			}
+			inst.Labels = claim.labels(inst.Labels)
+		}
+
+		session, err := o.sessionFromInstance(ctx, inst)
+		if err != nil {
+			log.Printf("stopping unrecoverable browser instance %s: %v", inst.ID, err)
+			o.runtime.Stop(ctx, inst.ID)
+			continue
+		}
+
+		if now.After(session.MaxExpiresAt) {
+			log.Printf("stopping expired session %s", session.SessionID)
+			o.runtime.Stop(ctx, inst.ID)
+			continue
+		}
+
+		if err := o.checkHealth(ctx, session); err != nil {
+			log.Printf("stopping unhealthy session %s: %v", session.SessionID, err)
+			o.runtime.Stop(ctx, inst.ID)
+			continue
+		}
+
+		o.mu.Lock()
+		o.sessions[session.SessionID] = session
+		o.mu.Unlock()
+		o.admission.adopt(session.ClientID)
+		adopted[inst.ID] = true
+		log.Printf("recovered session %s (expires %s)", session.SessionID, session.ExpiresAt.Format(time.RFC3339))
+	}
+	o.claims.retain(adopted)
+}
+
+// pooledInstance reports whether inst was started for the warm pool.
+// Instances from before labelState were pooled if they had no client.
+func pooledInstance(inst Instance) bool {
+	switch inst.Labels[labelState] {
+	case statePooled:
+		return true
+	case "":
+		return inst.Labels[labelClientID] == ""
+	}
+	return false
+}
+
+func (o *Orchestrator) sessionFromInstance(ctx context.Context, inst Instance) (*shared.Session, error) {
+	sessionID := inst.Labels[labelSessionID]
+	if sessionID == "" {
+		return nil, fmt.Errorf("missing %s label", labelSessionID)
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, inst.Labels[labelExpiresAt])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s label: %w", labelExpiresAt, err)
+	}
+
+	maxExpiresAt, err := time.Parse(time.RFC3339, inst.Labels[labelMaxExpiry])
+	if err != nil {
+		maxExpiresAt = expiresAt
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, inst.Labels[labelCreatedAt])
+	if err != nil {
+		createdAt = expiresAt.Add(-o.sessionCfg.Timeout)
+	}
+
+	// Labels cannot follow extensions, so a live instance past its labeled
+	// expiry was extended before the restart. Give it a fresh timeout
+	// within its maximum lifetime.
+	now := time.Now().UTC()
+	if now.After(expiresAt) {
+		expiresAt = now.Add(o.sessionCfg.Timeout)
+		if expiresAt.After(maxExpiresAt) {
+			expiresAt = maxExpiresAt
+		}
+	}
+
+	var profile *shared.BrowserProfile
+	if raw := inst.Labels[labelProfile]; raw != "" {
+		profile = &shared.BrowserProfile{}
+		if err := json.Unmarshal([]byte(raw), profile); err != nil {
+			return nil, fmt.Errorf("invalid %s label: %w", labelProfile, err)
+		}
+	}
+
+	var egress *shared.EgressPolicy
+	if raw := inst.Labels[labelEgress]; raw != "" {
+		egress = &shared.EgressPolicy{}
+		if err := json.Unmarshal([]byte(raw), egress); err != nil {
+			return nil, fmt.Errorf("invalid %s label: %w", labelEgress, err)
+		}
+	}
+
+	host, port, err := o.runtime.Address(ctx, inst.ID)
+	if err != nil {
+		return nil, err
+	}
+	if labeled, err := strconv.Atoi(inst.Labels[labelAPIPort]); err == nil {
+		port = labeled
+	}
+
+	return &shared.Session{
+		SessionID:   sessionID,
+		ClientID:    inst.Labels[labelClientID],
+		Profile:     profile,
+		Egress:      egress,
+		ContainerID: inst.ID,
+		ContainerIP: host,
+		APIPort:     port,
+		CreatedAt:   createdAt.UTC(),
+		ExpiresAt:   expiresAt.UTC(),
+
+		MaxExpiresAt:   maxExpiresAt.UTC(),
+		LastActivityAt: now,
+	}, nil
+}
+
+func (o *Orchestrator) checkHealth(ctx context.Context, session *shared.Session) error {
+	healthURL := fmt.Sprintf("http://%s:%d/health", session.ContainerIP, session.APIPort)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package orchestrator
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReconcileAdoptsClaimedPooledBrowser(t *testing.T) {
+	ctx := context.Background()
+	runtime := newFakeRuntime(t)
+	cfg := Config{Pool: PoolConfig{Size: 1, ClaimsFile: filepath.Join(t.TempDir(), "pool_claims.json")}}
+
+	before := NewOrchestrator(runtime, cfg)
+	before.fillPool(ctx)
+	session, err := before.CreateSession(ctx, SessionOptions{ClientID: "client-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running := runtime.running(); len(running) != 1 || running[0] != session.ContainerID {
+		t.Fatalf("session did not come from the pool: running %v, session on %s", running, session.ContainerID)
+	}
+	extended, err := before.ExtendSession(session.SessionID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Refill so an idle pooled browser is left behind as well.
+	before.fillPool(ctx)
+	var idleID string
+	for _, id := range runtime.running() {
+		if id != session.ContainerID {
+			idleID = id
+		}
+	}
+	if idleID == "" {
+		t.Fatal("pool was not refilled")
+	}
+
+	after := NewOrchestrator(runtime, cfg)
+	after.reconcile(ctx)
+
+	adopted, ok := after.GetSession(session.SessionID)
+	if !ok {
+		t.Fatalf("claimed pooled browser was not adopted")
+	}
+	if adopted.ContainerID != session.ContainerID || adopted.ClientID != "client-a" {
+		t.Errorf("adopted %s for %q, want %s for client-a", adopted.ContainerID, adopted.ClientID, session.ContainerID)
+	}
+	if !adopted.ExpiresAt.Equal(extended.ExpiresAt.Truncate(time.Second)) {
+		t.Errorf("adopted session expires %s, want the extended %s", adopted.ExpiresAt, extended.ExpiresAt)
+	}
+	if runtime.stopped(session.ContainerID) {
+		t.Error("claimed pooled browser was stopped")
+	}
+	if !runtime.stopped(idleID) {
+		t.Error("idle pooled browser was not stopped")
+	}
+
+	// Ending the session drops its claim, so the next restart stops
+	// nothing it should not.
+	if err := after.DestroySession(ctx, session.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := newClaimStoreLoaded(t, cfg.Pool.ClaimsFile).get(session.ContainerID); ok {
+		t.Error("claim kept after the session ended")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		unhealthy bool
+		wantAdopt bool
+	}{
+		{
+			name:      "live session",
+			labels:    sessionLabels("s-live", "client", stateSession, defaultTestProfile(), defaultTestEgress(), now, now.Add(time.Hour), now.Add(2*time.Hour)),
+			wantAdopt: true,
+		},
+		{
+			name:      "extended past labeled expiry",
+			labels:    sessionLabels("s-extended", "client", stateSession, defaultTestProfile(), defaultTestEgress(), now.Add(-time.Hour), now.Add(-time.Minute), now.Add(time.Hour)),
+			wantAdopt: true,
+		},
+		{
+			name:   "past maximum lifetime",
+			labels: sessionLabels("s-expired", "client", stateSession, defaultTestProfile(), defaultTestEgress(), now.Add(-3*time.Hour), now.Add(-2*time.Hour), now.Add(-time.Hour)),
+		},
+		{
+			name:   "unclaimed pooled browser",
+			labels: sessionLabels("s-pooled", "", statePooled, defaultTestProfile(), defaultTestEgress(), now, now.Add(time.Hour), now.Add(time.Hour)),
+		},
+		{
+			name:   "pooled browser from before state labels",
+			labels: sessionLabels("s-old-pooled", "", "", defaultTestProfile(), defaultTestEgress(), now, now.Add(time.Hour), now.Add(time.Hour)),
+		},
+		{
+			name:   "missing expiry",
+			labels: map[string]string{labelSessionID: "s-broken", labelClientID: "client", labelState: stateSession},
+		},
+		{
+			name:      "unhealthy agent",
+			labels:    sessionLabels("s-unhealthy", "client", stateSession, defaultTestProfile(), defaultTestEgress(), now, now.Add(time.Hour), now.Add(2*time.Hour)),
+			unhealthy: true,
+		},
+		{
+			name: "invalid profile label",
+			labels: func() map[string]string {
+				labels := sessionLabels("s-bad-profile", "client", stateSession, defaultTestProfile(), defaultTestEgress(), now, now.Add(time.Hour), now.Add(2*time.Hour))
+				labels[labelProfile] = "{"
+				return labels
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			runtime := newFakeRuntime(t)
+			id, _ := runtime.Create(ctx, BrowserSpec{Labels: tt.labels})
+			runtime.Start(ctx, id)
+			if tt.unhealthy {
+				runtime.instances[id].labels[labelAPIPort] = strconv.Itoa(closedPort(t))
+			}
+
+			o := NewOrchestrator(runtime, Config{})
+			o.reconcile(ctx)
+
+			_, adopted := o.GetSession(tt.labels[labelSessionID])
+			if adopted != tt.wantAdopt {
+				t.Errorf("adopted = %v, want %v", adopted, tt.wantAdopt)
+			}
+			if runtime.stopped(id) == tt.wantAdopt {
+				t.Errorf("stopped = %v, want %v", runtime.stopped(id), !tt.wantAdopt)
+			}
+		})
+	}
+}
+
+func TestReconcileRestoresSession(t *testing.T) {
+	ctx := context.Background()
+	runtime := newFakeRuntime(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	profile := defaultTestProfile()
+	profile.Locale = "de-DE"
+	labels := sessionLabels("s-restored", "client-a", stateSession, profile, defaultTestEgress(), now.Add(-time.Hour), now.Add(10*time.Minute), now.Add(time.Hour))
+	id, _ := runtime.Create(ctx, BrowserSpec{Labels: labels})
+	runtime.Start(ctx, id)
+
+	o := NewOrchestrator(runtime, Config{Limits: LimitsConfig{MaxSessions: 1}})
+	o.reconcile(ctx)
+
+	session, ok := o.GetSession("s-restored")
+	if !ok {
+		t.Fatal("session was not adopted")
+	}
+	if session.ClientID != "client-a" || session.ContainerID != id {
+		t.Errorf("adopted %s for %q, want %s for client-a", session.ContainerID, session.ClientID, id)
+	}
+	if !session.CreatedAt.Equal(now.Add(-time.Hour)) || !session.ExpiresAt.Equal(now.Add(10*time.Minute)) || !session.MaxExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("lifetime %s to %s (max %s) does not match the labels", session.CreatedAt, session.ExpiresAt, session.MaxExpiresAt)
+	}
+	if session.Profile == nil || session.Profile.Locale != "de-DE" {
+		t.Errorf("profile %+v not restored from labels", session.Profile)
+	}
+
+	// The adopted session holds the only slot.
+	if _, err := o.CreateSession(ctx, SessionOptions{ClientID: "client-b"}); err == nil {
+		t.Error("adopted session was not counted against MaxSessions")
+	}
+}
+
+// closedPort returns a local port nothing listens on.
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func newClaimStoreLoaded(t *testing.T, path string) *claimStore {
+	t.Helper()
+	store := newClaimStore(path)
+	if err := store.load(); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
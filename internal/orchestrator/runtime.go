@@ -0,0 +1,41 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+// ErrStatsUnavailable is returned by runtimes that cannot report resource
+// usage.
+var ErrStatsUnavailable = errors.New("resource usage not available")
+
+// BrowserSpec describes a browser agent instance to launch.
+type BrowserSpec struct {
+	Name        string
+	Image       string
+	Env         []string
+	Labels      map[string]string
+	Egress      EgressRules
+	MemoryBytes int64
+	NanoCPUs    int64
+}
+
+// Runtime launches and tears down browser agent instances. The returned
+// instance ID is opaque to the orchestrator. Stop releases an instance
+// whether or not it was started.
+type Runtime interface {
+	Create(ctx context.Context, spec BrowserSpec) (string, error)
+	Start(ctx context.Context, id string) error
+	Address(ctx context.Context, id string) (host string, port int, err error)
+	Stop(ctx context.Context, id string) error
+	List(ctx context.Context) ([]Instance, error)
+	Stats(ctx context.Context, id string) (*shared.ResourceUsage, error)
+}
+
+// Instance is a running browser agent discovered through Runtime.List.
+type Instance struct {
+	ID     string
+	Labels map[string]string
+}
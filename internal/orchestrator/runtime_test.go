@@ -0,0 +1,138 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+// fakeRuntime is an in-memory Runtime whose instances all answer health
+// checks through one test server.
+type fakeRuntime struct {
+	host string
+	port int
+
+	mu        sync.Mutex
+	nextID    int
+	instances map[string]*fakeInstance
+	// failStart and failAddress make those calls fail, for exercising
+	// launch error paths.
+	failStart   bool
+	failAddress bool
+}
+
+type fakeInstance struct {
+	spec    BrowserSpec
+	labels  map[string]string
+	started bool
+	stopped bool
+}
+
+func newFakeRuntime(t *testing.T) *fakeRuntime {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	return &fakeRuntime{host: host, port: port, instances: make(map[string]*fakeInstance)}
+}
+
+func (r *fakeRuntime) Create(ctx context.Context, spec BrowserSpec) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	id := fmt.Sprintf("fake-%d", r.nextID)
+	r.instances[id] = &fakeInstance{spec: spec, labels: withAPIPort(spec.Labels, r.port)}
+	return id, nil
+}
+
+func (r *fakeRuntime) Start(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	inst, ok := r.instances[id]
+	if !ok {
+		return fmt.Errorf("no instance %s", id)
+	}
+	if r.failStart {
+		return fmt.Errorf("start failed")
+	}
+	inst.started = true
+	return nil
+}
+
+func (r *fakeRuntime) Address(ctx context.Context, id string) (string, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failAddress {
+		return "", 0, fmt.Errorf("no address")
+	}
+	return r.host, r.port, nil
+}
+
+func (r *fakeRuntime) Stop(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	inst, ok := r.instances[id]
+	if !ok {
+		return fmt.Errorf("no instance %s", id)
+	}
+	inst.stopped = true
+	return nil
+}
+
+func (r *fakeRuntime) List(ctx context.Context) ([]Instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var instances []Instance
+	for id, inst := range r.instances {
+		if inst.started && !inst.stopped {
+			instances = append(instances, Instance{ID: id, Labels: inst.labels})
+		}
+	}
+	return instances, nil
+}
+
+func (r *fakeRuntime) Stats(ctx context.Context, id string) (*shared.ResourceUsage, error) {
+	return nil, ErrStatsUnavailable
+}
+
+// running returns the IDs of started instances that have not been stopped.
+func (r *fakeRuntime) running() []string {
+	instances, _ := r.List(context.Background())
+	ids := make([]string, len(instances))
+	for i, inst := range instances {
+		ids[i] = inst.ID
+	}
+	return ids
+}
+
+func (r *fakeRuntime) stopped(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	inst, ok := r.instances[id]
+	return ok && inst.stopped
+}
+
+func defaultTestProfile() shared.BrowserProfile {
+	return shared.BrowserProfile{Name: defaultProfileName, Image: browserImage}
+}
+
+func defaultTestEgress() shared.EgressPolicy {
+	return shared.EgressPolicy{}
+}
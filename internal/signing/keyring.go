@@ -0,0 +1,63 @@
+package signing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+// LoadKeyring reads the node's published keys from path and adds current's
+// public key if it is new, retiring the keys it replaces. The file only
+// ever gains keys, so manifests signed before a rotation keep verifying.
+func LoadKeyring(path string, current *Signer) (shared.NodeKeys, error) {
+	var keys shared.NodeKeys
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return shared.NodeKeys{}, fmt.Errorf("failed to load keyring %s: %w", path, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return shared.NodeKeys{}, err
+	}
+
+	if _, ok := keys.Find(current.KeyID()); ok {
+		return keys, nil
+	}
+
+	now := time.Now().UTC()
+	for i := range keys.Keys {
+		if keys.Keys[i].RetiredAt == nil {
+			keys.Keys[i].RetiredAt = &now
+		}
+	}
+	keys.Keys = append(keys.Keys, current.PublicKey())
+
+	if err := saveKeyring(path, keys); err != nil {
+		return shared.NodeKeys{}, fmt.Errorf("failed to save keyring: %w", err)
+	}
+	return keys, nil
+}
+
+func saveKeyring(path string, keys shared.NodeKeys) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := writeTemp(dir, filepath.Base(path), data, 0o644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,50 @@
+package signing
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node_keys.json")
+	first := newTestSigner(t)
+
+	keys, err := LoadKeyring(path, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.Keys) != 1 || keys.Keys[0].KeyID != first.KeyID() || keys.Keys[0].RetiredAt != nil {
+		t.Fatalf("got keys %+v, want only the active key %s", keys.Keys, first.KeyID())
+	}
+
+	// Restarting with the same key leaves the keyring alone.
+	if keys, err = LoadKeyring(path, first); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.Keys) != 1 {
+		t.Fatalf("got %d keys after restart, want 1", len(keys.Keys))
+	}
+
+	second := newTestSigner(t)
+	if keys, err = LoadKeyring(path, second); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.Keys) != 2 {
+		t.Fatalf("got %d keys after rotation, want 2", len(keys.Keys))
+	}
+
+	retired, ok := keys.Find(first.KeyID())
+	if !ok || retired.RetiredAt == nil {
+		t.Errorf("rotated key %s is not kept as retired: %+v", first.KeyID(), retired)
+	}
+	active, ok := keys.Find(second.KeyID())
+	if !ok || active.RetiredAt != nil {
+		t.Errorf("new key %s is not active: %+v", second.KeyID(), active)
+	}
+
+	// Manifests signed before the rotation verify with the retired key.
+	canonical := []byte(`{}`)
+	if err := Verify(retired, canonical, first.SignManifest(canonical)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,184 @@
+// Package signing holds the node's own Ed25519 key, used to sign capture
+// manifests independently of the event log.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const Algorithm = "ed25519"
+
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{key: key, keyID: KeyID(key.Public().(ed25519.PublicKey))}
+}
+
+// LoadOrCreate reads a PKCS#8 PEM private key from path, generating and
+// saving a new key if the file does not exist yet.
+func LoadOrCreate(path string) (*Signer, bool, error) {
+	signer, err := load(path)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return signer, false, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, err
+	}
+	return create(path, key)
+}
+
+func load(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// create saves key at path unless a key is already there, in which case
+// that key is loaded instead, so two nodes starting together agree on one.
+func create(path string, key ed25519.PrivateKey) (*Signer, bool, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, false, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	tmp, err := writeTemp(dir, filepath.Base(path), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	// Linking rather than renaming puts the complete file in place only if
+	// path does not exist yet.
+	if err := os.Link(tmp, path); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			signer, err := load(path)
+			return signer, false, err
+		}
+		return nil, false, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	return NewSigner(key), true, nil
+}
+
+// writeTemp writes data to a new synced file in dir and returns its path.
+func writeTemp(dir, name string, data []byte, perm os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+
+	err = tmp.Chmod(perm)
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func parseKey(data []byte) (*Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("expected a PEM encoded PKCS#8 private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is not an Ed25519 key")
+	}
+	return NewSigner(key), nil
+}
+
+// KeyID identifies a public key by the first 16 bytes of its SHA-256.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:16])
+}
+
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey describes the node's verification key for publication.
+func (s *Signer) PublicKey() shared.NodeKey {
+	pub := s.key.Public().(ed25519.PublicKey)
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	return shared.NodeKey{
+		KeyID:     s.keyID,
+		Algorithm: Algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		PEM:       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	}
+}
+
+// SignManifest signs the canonical manifest bytes.
+func (s *Signer) SignManifest(canonical []byte) *shared.ManifestSignature {
+	return &shared.ManifestSignature{
+		Algorithm:      Algorithm,
+		KeyID:          s.keyID,
+		ManifestSHA256: shared.SHA256Hex(canonical),
+		Signature:      base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, canonical)),
+		SignedAt:       time.Now().UTC(),
+	}
+}
+
+// Verify checks sig over the canonical manifest bytes with key.
+func Verify(key shared.NodeKey, canonical []byte, sig *shared.ManifestSignature) error {
+	if sig.Algorithm != Algorithm || key.Algorithm != Algorithm {
+		return fmt.Errorf("unsupported algorithm")
+	}
+	if sig.KeyID != key.KeyID {
+		return fmt.Errorf("signature was made with key %s, not %s", sig.KeyID, key.KeyID)
+	}
+	pub, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding")
+	}
+	if !ed25519.Verify(pub, canonical, signature) {
+		return fmt.Errorf("signature does not verify")
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+// FilesystemStorage keeps captures in a local directory tree, mirroring the
+// object layout used by MinIOStorage.
+type FilesystemStorage struct {
+	root      string
+	publicURL string
+}
+
+func NewFilesystemStorage(root, publicURL string) (*FilesystemStorage, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage path: %w", err)
+	}
+
+	if err := os.MkdirAll(absRoot, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	return &FilesystemStorage{
+		root:      absRoot,
+		publicURL: strings.TrimRight(publicURL, "/"),
+	}, nil
+}
+
+func (s *FilesystemStorage) StoreManifest(ctx context.Context, captureID string, canonical []byte) error {
+	return s.putFile(captureID, "manifest.json", canonical)
+}
+
+func (s *FilesystemStorage) StoreEvent(ctx context.Context, captureID string, event *shared.CaptureEvent) error {
+	data, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.putFile(captureID, "event.json", data)
+}
+
+func (s *FilesystemStorage) StoreArtifact(ctx context.Context, captureID, name string, data []byte, contentType string) error {
+	return s.putFile(captureID, name, data)
+}
+
+func (s *FilesystemStorage) GetManifest(ctx context.Context, captureID string) (*shared.Manifest, error) {
+	data, err := s.getFile(captureID, "manifest.json")
+	if err != nil {
+		return nil, err
+	}
+
+	return shared.ParseManifest(data)
+}
+
+func (s *FilesystemStorage) GetEvent(ctx context.Context, captureID string) (*shared.CaptureEvent, error) {
+	data, err := s.getFile(captureID, "event.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var event shared.CaptureEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *FilesystemStorage) GetArtifact(ctx context.Context, captureID, name string) ([]byte, error) {
+	return s.getFile(captureID, name)
+}
+
+// GetArtifactURL points at the API route serving the artifact, since a
+// local directory is not served on its own.
+func (s *FilesystemStorage) GetArtifactURL(captureID, name string) (string, error) {
+	if _, err := capturePath(captureID, name); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/captures/%s/artifacts/%s", s.publicURL, captureID, name), nil
+}
+
+// GetPresignedArtifactURL returns the public URL; a local directory has no
+// notion of signed, expiring links.
+func (s *FilesystemStorage) GetPresignedArtifactURL(ctx context.Context, captureID, name string, expiry time.Duration) (string, error) {
+	return s.GetArtifactURL(captureID, name)
+}
+
+func (s *FilesystemStorage) CaptureExists(ctx context.Context, captureID string) (bool, error) {
+	path, err := s.filePath(captureID, shared.ManifestFile)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FilesystemStorage) filePath(captureID, name string) (string, error) {
+	path, err := capturePath(captureID, name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(path)), nil
+}
+
+func (s *FilesystemStorage) putFile(captureID, name string, data []byte) error {
+	path, err := s.filePath(captureID, name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial artifact.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FilesystemStorage) getFile(captureID, name string) ([]byte, error) {
+	path, err := s.filePath(captureID, name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
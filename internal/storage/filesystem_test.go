@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const testCaptureID = "0b8e6f1e-3c59-4a55-9d3c-5f1a8e2b7c10"
+
+func newTestFilesystem(t *testing.T) (*FilesystemStorage, string) {
+	t.Helper()
+	root := t.TempDir()
+	s, err := NewFilesystemStorage(root, "http://node.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, root
+}
+
+func TestFilesystemArtifacts(t *testing.T) {
+	ctx := context.Background()
+	s, root := newTestFilesystem(t)
+
+	data := []byte("<html></html>")
+	if err := s.StoreArtifact(ctx, testCaptureID, shared.DOMFile, data, shared.MediaTypeHTML); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetArtifact(ctx, testCaptureID, shared.DOMFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("read back %q, want %q", got, data)
+	}
+
+	// The tree mirrors the object layout.
+	onDisk := filepath.Join(root, filepath.FromSlash(shared.ArtifactPath(testCaptureID, shared.DOMFile)))
+	if _, err := os.Stat(onDisk); err != nil {
+		t.Errorf("artifact not at %s: %v", onDisk, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(onDisk))
+	if len(entries) != 1 {
+		t.Errorf("capture directory holds %d entries, want only the artifact", len(entries))
+	}
+
+	if _, err := s.GetArtifact(ctx, testCaptureID, shared.PDFFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing artifact: %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestFilesystemManifestAndEvent(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestFilesystem(t)
+
+	exists, err := s.CaptureExists(ctx, testCaptureID)
+	if err != nil || exists {
+		t.Fatalf("CaptureExists before storing = %v, %v", exists, err)
+	}
+
+	canonical, err := shared.CanonicalJSON(&shared.Manifest{
+		Version:          shared.ManifestVersion,
+		Canonicalization: shared.CanonicalizationJCS,
+		CaptureID:        testCaptureID,
+		URL:              "https://example.com/",
+		CapturedAtUTC:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Artifacts:        []shared.Artifact{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreManifest(ctx, testCaptureID, canonical); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.GetArtifact(ctx, testCaptureID, shared.ManifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, canonical) {
+		t.Errorf("stored manifest differs from the canonical encoding:\n%s\n%s", stored, canonical)
+	}
+	manifest, err := s.GetManifest(ctx, testCaptureID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.CaptureID != testCaptureID || manifest.URL != "https://example.com/" {
+		t.Errorf("parsed manifest %+v", manifest)
+	}
+
+	exists, err = s.CaptureExists(ctx, testCaptureID)
+	if err != nil || !exists {
+		t.Errorf("CaptureExists after storing = %v, %v", exists, err)
+	}
+
+	event := &shared.CaptureEvent{EventID: "e1", CaptureID: testCaptureID, EventHash: "abc"}
+	if err := s.StoreEvent(ctx, testCaptureID, event); err != nil {
+		t.Fatal(err)
+	}
+	gotEvent, err := s.GetEvent(ctx, testCaptureID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotEvent.EventID != "e1" || gotEvent.EventHash != "abc" {
+		t.Errorf("read back event %+v", gotEvent)
+	}
+}
+
+func TestFilesystemRejectsEscapingPaths(t *testing.T) {
+	ctx := context.Background()
+	s, root := newTestFilesystem(t)
+
+	tests := []struct {
+		name      string
+		captureID string
+		artifact  string
+	}{
+		{"parent capture id", "..", shared.DOMFile},
+		{"nested capture id", "a/../../b", shared.DOMFile},
+		{"empty capture id", "", shared.DOMFile},
+		{"parent artifact", testCaptureID, "../escape"},
+		{"artifact with separator", testCaptureID, "sub/file"},
+		{"artifact with backslash", testCaptureID, `..\escape`},
+		{"empty artifact", testCaptureID, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.StoreArtifact(ctx, tt.captureID, tt.artifact, []byte("x"), "text/plain"); err == nil {
+				t.Error("StoreArtifact accepted the path")
+			}
+			if _, err := s.GetArtifact(ctx, tt.captureID, tt.artifact); err == nil || errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("GetArtifact: %v, want a validation error", err)
+			}
+			if _, err := s.GetArtifactURL(tt.captureID, tt.artifact); err == nil {
+				t.Error("GetArtifactURL accepted the path")
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == "escape" || entry.Name() == "b" {
+			t.Errorf("file written outside the storage root: %s", entry.Name())
+		}
+	}
+}
+
+func TestFilesystemArtifactURL(t *testing.T) {
+	s, _ := newTestFilesystem(t)
+	want := "http://node.example/captures/" + testCaptureID + "/artifacts/" + shared.DOMFile
+
+	url, err := s.GetArtifactURL(testCaptureID, shared.DOMFile)
+	if err != nil || url != want {
+		t.Errorf("GetArtifactURL = %q, %v; want %q", url, err, want)
+	}
+	presigned, err := s.GetPresignedArtifactURL(context.Background(), testCaptureID, shared.DOMFile, time.Hour)
+	if err != nil || presigned != want {
+		t.Errorf("GetPresignedArtifactURL = %q, %v; want %q", presigned, err, want)
+	}
+}
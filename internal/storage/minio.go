@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/intraceai/capture-node/pkg/shared"
@@ -14,9 +15,9 @@ import (
 )
 
 type MinIOStorage struct {
-	client     *minio.Client
-	bucket     string
-	publicURL  string
+	client    *minio.Client
+	bucket    string
+	publicURL string
 }
 
 func NewMinIOStorage(endpoint, accessKey, secretKey, bucket string, useSSL bool, publicURL string) (*MinIOStorage, error) {
@@ -31,7 +32,7 @@ func NewMinIOStorage(endpoint, accessKey, secretKey, bucket string, useSSL bool,
 	return &MinIOStorage{
 		client:    client,
 		bucket:    bucket,
-		publicURL: publicURL,
+		publicURL: strings.TrimRight(publicURL, "/"),
 	}, nil
 }
 
@@ -66,33 +67,29 @@ func (s *MinIOStorage) EnsureBucket(ctx context.Context) error {
 	return nil
 }
 
-func (s *MinIOStorage) StoreScreenshot(ctx context.Context, captureID string, data []byte) error {
-	path := fmt.Sprintf("captures/%s/screenshot.png", captureID)
-	reader := bytes.NewReader(data)
-
-	_, err := s.client.PutObject(ctx, s.bucket, path, reader, int64(len(data)), minio.PutObjectOptions{
-		ContentType: "image/png",
-	})
-	return err
-}
-
-func (s *MinIOStorage) StoreDOM(ctx context.Context, captureID string, data []byte) error {
-	path := fmt.Sprintf("captures/%s/dom.html", captureID)
-	reader := bytes.NewReader(data)
+func (s *MinIOStorage) StoreManifest(ctx context.Context, captureID string, canonical []byte) error {
+	path, err := capturePath(captureID, "manifest.json")
+	if err != nil {
+		return err
+	}
+	reader := bytes.NewReader(canonical)
 
-	_, err := s.client.PutObject(ctx, s.bucket, path, reader, int64(len(data)), minio.PutObjectOptions{
-		ContentType: "text/html; charset=utf-8",
+	_, err = s.client.PutObject(ctx, s.bucket, path, reader, int64(len(canonical)), minio.PutObjectOptions{
+		ContentType: "application/json",
 	})
 	return err
 }
 
-func (s *MinIOStorage) StoreManifest(ctx context.Context, captureID string, manifest *shared.Manifest) error {
-	data, err := json.MarshalIndent(manifest, "", "  ")
+func (s *MinIOStorage) StoreEvent(ctx context.Context, captureID string, event *shared.CaptureEvent) error {
+	data, err := json.MarshalIndent(event, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	path := fmt.Sprintf("captures/%s/manifest.json", captureID)
+	path, err := capturePath(captureID, "event.json")
+	if err != nil {
+		return err
+	}
 	reader := bytes.NewReader(data)
 
 	_, err = s.client.PutObject(ctx, s.bucket, path, reader, int64(len(data)), minio.PutObjectOptions{
@@ -101,48 +98,30 @@ func (s *MinIOStorage) StoreManifest(ctx context.Context, captureID string, mani
 	return err
 }
 
-func (s *MinIOStorage) StoreEvent(ctx context.Context, captureID string, event *shared.CaptureEvent) error {
-	data, err := json.MarshalIndent(event, "", "  ")
+func (s *MinIOStorage) StoreArtifact(ctx context.Context, captureID, name string, data []byte, contentType string) error {
+	path, err := capturePath(captureID, name)
 	if err != nil {
 		return err
 	}
-
-	path := fmt.Sprintf("captures/%s/event.json", captureID)
 	reader := bytes.NewReader(data)
 
 	_, err = s.client.PutObject(ctx, s.bucket, path, reader, int64(len(data)), minio.PutObjectOptions{
-		ContentType: "application/json",
+		ContentType: contentType,
 	})
 	return err
 }
 
-func (s *MinIOStorage) GetScreenshot(ctx context.Context, captureID string) ([]byte, error) {
-	path := fmt.Sprintf("captures/%s/screenshot.png", captureID)
-	return s.getObject(ctx, path)
-}
-
-func (s *MinIOStorage) GetDOM(ctx context.Context, captureID string) ([]byte, error) {
-	path := fmt.Sprintf("captures/%s/dom.html", captureID)
-	return s.getObject(ctx, path)
-}
-
 func (s *MinIOStorage) GetManifest(ctx context.Context, captureID string) (*shared.Manifest, error) {
-	path := fmt.Sprintf("captures/%s/manifest.json", captureID)
-	data, err := s.getObject(ctx, path)
+	data, err := s.getObject(ctx, captureID, "manifest.json")
 	if err != nil {
 		return nil, err
 	}
 
-	var manifest shared.Manifest
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		return nil, err
-	}
-	return &manifest, nil
+	return shared.ParseManifest(data)
 }
 
 func (s *MinIOStorage) GetEvent(ctx context.Context, captureID string) (*shared.CaptureEvent, error) {
-	path := fmt.Sprintf("captures/%s/event.json", captureID)
-	data, err := s.getObject(ctx, path)
+	data, err := s.getObject(ctx, captureID, "event.json")
 	if err != nil {
 		return nil, err
 	}
@@ -154,22 +133,25 @@ func (s *MinIOStorage) GetEvent(ctx context.Context, captureID string) (*shared.
 	return &event, nil
 }
 
-func (s *MinIOStorage) getObject(ctx context.Context, path string) ([]byte, error) {
-	obj, err := s.client.GetObject(ctx, s.bucket, path, minio.GetObjectOptions{})
-	if err != nil {
-		return nil, err
-	}
-	defer obj.Close()
-
-	return io.ReadAll(obj)
+func (s *MinIOStorage) GetArtifact(ctx context.Context, captureID, name string) ([]byte, error) {
+	return s.getObject(ctx, captureID, name)
 }
 
-func (s *MinIOStorage) GetScreenshotURL(captureID string) string {
-	return fmt.Sprintf("%s/%s/captures/%s/screenshot.png", s.publicURL, s.bucket, captureID)
+// GetArtifactURL addresses the object directly; EnsureBucket makes the
+// bucket publicly readable.
+func (s *MinIOStorage) GetArtifactURL(captureID, name string) (string, error) {
+	path, err := capturePath(captureID, name)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/%s", s.publicURL, s.bucket, path), nil
 }
 
-func (s *MinIOStorage) GetPresignedScreenshotURL(ctx context.Context, captureID string, expiry time.Duration) (string, error) {
-	path := fmt.Sprintf("captures/%s/screenshot.png", captureID)
+func (s *MinIOStorage) GetPresignedArtifactURL(ctx context.Context, captureID, name string, expiry time.Duration) (string, error) {
+	path, err := capturePath(captureID, name)
+	if err != nil {
+		return "", err
+	}
 	url, err := s.client.PresignedGetObject(ctx, s.bucket, path, expiry, nil)
 	if err != nil {
 		return "", err
@@ -178,8 +160,11 @@ func (s *MinIOStorage) GetPresignedScreenshotURL(ctx context.Context, captureID
 }
 
 func (s *MinIOStorage) CaptureExists(ctx context.Context, captureID string) (bool, error) {
-	path := fmt.Sprintf("captures/%s/manifest.json", captureID)
-	_, err := s.client.StatObject(ctx, s.bucket, path, minio.StatObjectOptions{})
+	path, err := capturePath(captureID, shared.ManifestFile)
+	if err != nil {
+		return false, err
+	}
+	_, err = s.client.StatObject(ctx, s.bucket, path, minio.StatObjectOptions{})
 	if err != nil {
 		errResp := minio.ToErrorResponse(err)
 		if errResp.Code == "NoSuchKey" {
@@ -189,3 +174,17 @@ func (s *MinIOStorage) CaptureExists(ctx context.Context, captureID string) (boo
 	}
 	return true, nil
 }
+
+func (s *MinIOStorage) getObject(ctx context.Context, captureID, name string) ([]byte, error) {
+	path, err := capturePath(captureID, name)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, path, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	return io.ReadAll(obj)
+}
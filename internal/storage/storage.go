@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+// Backend persists capture artifacts using the captures/<id>/... layout.
+// Artifacts are stored and read by file name.
+type Backend interface {
+	// StoreManifest stores the manifest's canonical encoding unchanged, so
+	// manifest.json is exactly the bytes the manifest hash covers.
+	StoreManifest(ctx context.Context, captureID string, canonical []byte) error
+	StoreEvent(ctx context.Context, captureID string, event *shared.CaptureEvent) error
+	StoreArtifact(ctx context.Context, captureID, name string, data []byte, contentType string) error
+
+	GetManifest(ctx context.Context, captureID string) (*shared.Manifest, error)
+	GetEvent(ctx context.Context, captureID string) (*shared.CaptureEvent, error)
+	GetArtifact(ctx context.Context, captureID, name string) ([]byte, error)
+
+	// GetArtifactURL is where clients can fetch the artifact without
+	// credentials; GetPresignedArtifactURL is a link that expires.
+	GetArtifactURL(captureID, name string) (string, error)
+	GetPresignedArtifactURL(ctx context.Context, captureID, name string, expiry time.Duration) (string, error)
+	CaptureExists(ctx context.Context, captureID string) (bool, error)
+}
+
+var (
+	_ Backend = (*MinIOStorage)(nil)
+	_ Backend = (*FilesystemStorage)(nil)
+)
+
+// capturePath returns the object path of a capture's file, rejecting IDs
+// and names that could escape the capture's directory.
+func capturePath(captureID, name string) (string, error) {
+	if !validPathElement(captureID) {
+		return "", fmt.Errorf("invalid capture id %q", captureID)
+	}
+	if !validPathElement(name) {
+		return "", fmt.Errorf("invalid artifact name %q", name)
+	}
+	return shared.ArtifactPath(captureID, name), nil
+}
+
+func validPathElement(elem string) bool {
+	return elem != "" && !strings.Contains(elem, "..") && !strings.ContainsAny(elem, `/\`)
+}
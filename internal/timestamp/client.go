@@ -0,0 +1,119 @@
+// Package timestamp obtains RFC 3161 timestamp tokens for manifest hashes
+// from a time-stamping authority (TSA).
+package timestamp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultTimeout = 15 * time.Second
+	maxResponse    = 1 << 20
+
+	mediaTypeQuery = "application/timestamp-query"
+	mediaTypeReply = "application/timestamp-reply"
+)
+
+type Client struct {
+	URL     string
+	Timeout time.Duration
+	// Roots, when set, must anchor the TSA certificate. Nil only checks the
+	// token's signature against the certificate it carries.
+	Roots *x509.CertPool
+}
+
+func NewClient(url string) *Client {
+	return &Client{URL: url, Timeout: defaultTimeout}
+}
+
+// Timestamp requests a token over the hex SHA-256 digest and accepts it only
+// if it is signed and covers exactly that digest and the request's nonce.
+func (c *Client) Timestamp(ctx context.Context, sha256Hex string) (*Token, error) {
+	digest, err := hex.DecodeString(sha256Hex)
+	if err != nil || len(digest) != crypto.SHA256.Size() {
+		return nil, fmt.Errorf("invalid SHA-256 digest")
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	query, err := asn1.Marshal(TimeStampReq{
+		Version: 1,
+		MessageImprint: MessageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: OIDSHA256},
+			HashedMessage: digest,
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mediaTypeQuery)
+	req.Header.Set("Accept", mediaTypeReply)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("TSA request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TSA returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if err != nil {
+		return nil, err
+	}
+
+	var reply TimeStampResp
+	if rest, err := asn1.Unmarshal(body, &reply); err != nil {
+		return nil, fmt.Errorf("invalid TSA response: %w", err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("invalid TSA response: trailing data")
+	}
+	if status := reply.Status.Status; status != StatusGranted && status != StatusGrantedWithMods {
+		return nil, fmt.Errorf("TSA rejected request: status %d %v", status, reply.Status.StatusString)
+	}
+	if len(reply.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("TSA granted request without a token")
+	}
+
+	token, err := ParseToken(reply.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := token.VerifyImprint(crypto.SHA256, digest); err != nil {
+		return nil, err
+	}
+	if token.Info.Nonce == nil || token.Info.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("timestamp token nonce does not match the request")
+	}
+	if c.Roots != nil {
+		if err := token.VerifyChain(c.Roots); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
@@ -0,0 +1,147 @@
+package timestamp_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/intraceai/capture-node/internal/timestamp"
+	"github.com/intraceai/capture-node/internal/timestamp/tsatest"
+)
+
+func newTSA(t *testing.T) *tsatest.Server {
+	t.Helper()
+	tsa, err := tsatest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tsa.Close)
+	return tsa
+}
+
+func manifestHash() (string, []byte) {
+	digest := sha256.Sum256([]byte(`{"capture_id":"test"}`))
+	return hex.EncodeToString(digest[:]), digest[:]
+}
+
+func TestTimestamp(t *testing.T) {
+	tsa := newTSA(t)
+	client := timestamp.NewClient(tsa.URL)
+	client.Roots = tsa.Roots()
+
+	hash, digest := manifestHash()
+	token, err := client.Timestamp(context.Background(), hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored DER must verify on its own, as it does in an export.
+	parsed, err := timestamp.ParseToken(token.DER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.VerifyImprint(crypto.SHA256, digest); err != nil {
+		t.Error(err)
+	}
+	if err := parsed.VerifyChain(tsa.Roots()); err != nil {
+		t.Error(err)
+	}
+
+	summary := parsed.Summary()
+	if summary.HashedMessage != hash || summary.HashAlgorithm != "SHA-256" {
+		t.Errorf("summary covers %s %s, want SHA-256 %s", summary.HashAlgorithm, summary.HashedMessage, hash)
+	}
+	if summary.Policy != tsatest.Policy.String() {
+		t.Errorf("policy %s, want %s", summary.Policy, tsatest.Policy)
+	}
+}
+
+func TestTimestampRejected(t *testing.T) {
+	_, digest := manifestHash()
+	otherHash := sha256.Sum256([]byte("other"))
+
+	tests := []struct {
+		name    string
+		setup   func(tsa *tsatest.Server, client *timestamp.Client)
+		wantErr string
+	}{
+		{
+			name: "mismatched imprint",
+			setup: func(tsa *tsatest.Server, client *timestamp.Client) {
+				tsa.Tamper = func(info *timestamp.TSTInfo) {
+					info.MessageImprint.HashedMessage = otherHash[:]
+				}
+			},
+			wantErr: "imprint does not match",
+		},
+		{
+			name: "mismatched nonce",
+			setup: func(tsa *tsatest.Server, client *timestamp.Client) {
+				tsa.Tamper = func(info *timestamp.TSTInfo) {
+					info.Nonce = new(big.Int).Add(info.Nonce, big.NewInt(1))
+				}
+			},
+			wantErr: "nonce does not match",
+		},
+		{
+			name: "missing nonce",
+			setup: func(tsa *tsatest.Server, client *timestamp.Client) {
+				tsa.Tamper = func(info *timestamp.TSTInfo) {
+					info.Nonce = nil
+				}
+			},
+			wantErr: "nonce does not match",
+		},
+		{
+			name: "untrusted root",
+			setup: func(tsa *tsatest.Server, client *timestamp.Client) {
+				other := newTSA(t)
+				client.Roots = other.Roots()
+			},
+			wantErr: "not trusted",
+		},
+		{
+			name: "not granted",
+			setup: func(tsa *tsatest.Server, client *timestamp.Client) {
+				tsa.Reject = "policy not supported"
+			},
+			wantErr: "status 2 [policy not supported]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tsa := newTSA(t)
+			client := timestamp.NewClient(tsa.URL)
+			client.Roots = tsa.Roots()
+			tt.setup(tsa, client)
+
+			_, err := client.Timestamp(context.Background(), hex.EncodeToString(digest))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %q does not mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTokenTampered(t *testing.T) {
+	tsa := newTSA(t)
+	hash, _ := manifestHash()
+	token, err := timestamp.NewClient(tsa.URL).Timestamp(context.Background(), hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a byte of the signature, which ends the token.
+	der := append([]byte(nil), token.DER...)
+	der[len(der)-1] ^= 0xff
+	if _, err := timestamp.ParseToken(der); err == nil {
+		t.Error("expected a tampered token to fail")
+	}
+}
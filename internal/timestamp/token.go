@@ -0,0 +1,229 @@
+package timestamp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+// Token is a parsed RFC 3161 timestamp token whose CMS signature has been
+// checked against the certificate it carries.
+type Token struct {
+	DER    []byte
+	Info   TSTInfo
+	Signer *x509.Certificate
+	Chain  []*x509.Certificate
+}
+
+// ParseToken decodes a DER timestamp token (a CMS ContentInfo) and verifies
+// its signature.
+func ParseToken(der []byte) (*Token, error) {
+	var ci ContentInfo
+	if rest, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("invalid timestamp token: %w", err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("invalid timestamp token: trailing data")
+	}
+	if !ci.ContentType.Equal(OIDSignedData) {
+		return nil, fmt.Errorf("timestamp token is not CMS signed data")
+	}
+	if ci.Content.Class != asn1.ClassContextSpecific || ci.Content.Tag != 0 {
+		return nil, fmt.Errorf("invalid timestamp token: missing content")
+	}
+
+	var sd SignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("invalid signed data: %w", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(OIDTSTInfo) {
+		return nil, fmt.Errorf("signed data does not hold TSTInfo")
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("timestamp token must have exactly one signer")
+	}
+
+	token := &Token{DER: der}
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent, &token.Info); err != nil {
+		return nil, fmt.Errorf("invalid TSTInfo: %w", err)
+	}
+
+	if len(sd.Certificates.Bytes) > 0 {
+		certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificates in token: %w", err)
+		}
+		token.Chain = certs
+	}
+
+	si := sd.SignerInfos[0]
+	signer, err := findSigner(si.SID, token.Chain)
+	if err != nil {
+		return nil, err
+	}
+	token.Signer = signer
+
+	if err := verifySignerInfo(si, sd.EncapContentInfo.EContent, signer); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// VerifyImprint checks that the token covers digest computed with hash.
+func (t *Token) VerifyImprint(hash crypto.Hash, digest []byte) error {
+	imprintHash, err := hashFor(t.Info.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	if imprintHash != hash || !bytes.Equal(t.Info.MessageImprint.HashedMessage, digest) {
+		return fmt.Errorf("timestamp token imprint does not match the manifest hash")
+	}
+	return nil
+}
+
+// VerifyChain checks that the signing certificate chains to roots and is
+// issued for time stamping.
+func (t *Token) VerifyChain(roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range t.Chain {
+		intermediates.AddCert(cert)
+	}
+	_, err := t.Signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   t.Info.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return fmt.Errorf("TSA certificate not trusted: %w", err)
+	}
+	return nil
+}
+
+func (t *Token) Summary() *shared.TrustedTimestamp {
+	algorithm := "unknown"
+	if hash, err := hashFor(t.Info.MessageImprint.HashAlgorithm.Algorithm); err == nil {
+		algorithm = hash.String()
+	}
+	return &shared.TrustedTimestamp{
+		GenTime:       t.Info.GenTime.UTC(),
+		SerialNumber:  t.Info.SerialNumber.String(),
+		Policy:        t.Info.Policy.String(),
+		HashAlgorithm: algorithm,
+		HashedMessage: hex.EncodeToString(t.Info.MessageImprint.HashedMessage),
+		Signer:        t.Signer.Subject.String(),
+	}
+}
+
+func findSigner(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	switch {
+	case sid.Class == asn1.ClassUniversal && sid.Tag == asn1.TagSequence:
+		var ias IssuerAndSerialNumber
+		if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+			return nil, fmt.Errorf("invalid signer identifier: %w", err)
+		}
+		for _, cert := range certs {
+			if bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+				return cert, nil
+			}
+		}
+	case sid.Class == asn1.ClassContextSpecific && sid.Tag == 0:
+		for _, cert := range certs {
+			if bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("timestamp token does not include the signing certificate")
+}
+
+// verifySignerInfo checks the signed attributes bind the TSTInfo and that
+// the signer's key signed them (RFC 5652, section 5.4).
+func verifySignerInfo(si SignerInfo, content []byte, signer *x509.Certificate) error {
+	if len(si.SignedAttrs.FullBytes) == 0 {
+		return fmt.Errorf("timestamp token has no signed attributes")
+	}
+	hash, err := hashFor(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	// The signature covers the attributes with their universal SET tag
+	// instead of the [0] implicit tag they are sent with.
+	signedAttrs := append([]byte(nil), si.SignedAttrs.FullBytes...)
+	signedAttrs[0] = 0x31
+
+	var attrs []Attribute
+	if _, err := asn1.UnmarshalWithParams(signedAttrs, &attrs, "set"); err != nil {
+		return fmt.Errorf("invalid signed attributes: %w", err)
+	}
+
+	var contentType asn1.ObjectIdentifier
+	var messageDigest []byte
+	for _, attr := range attrs {
+		if len(attr.Values) != 1 {
+			continue
+		}
+		switch {
+		case attr.Type.Equal(OIDContentType):
+			asn1.Unmarshal(attr.Values[0].FullBytes, &contentType)
+		case attr.Type.Equal(OIDMessageDigest):
+			asn1.Unmarshal(attr.Values[0].FullBytes, &messageDigest)
+		}
+	}
+	if !contentType.Equal(OIDTSTInfo) {
+		return fmt.Errorf("signed attributes do not name TSTInfo")
+	}
+	h := hash.New()
+	h.Write(content)
+	if !bytes.Equal(h.Sum(nil), messageDigest) {
+		return fmt.Errorf("signed message digest does not match TSTInfo")
+	}
+
+	algorithm, err := signatureAlgorithm(signer, hash)
+	if err != nil {
+		return err
+	}
+	if err := signer.CheckSignature(algorithm, signedAttrs, si.Signature); err != nil {
+		return fmt.Errorf("timestamp token signature is invalid: %w", err)
+	}
+	return nil
+}
+
+func hashFor(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(OIDSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(OIDSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(OIDSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported digest algorithm %s", oid)
+}
+
+func signatureAlgorithm(cert *x509.Certificate, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	algorithms := map[x509.PublicKeyAlgorithm]map[crypto.Hash]x509.SignatureAlgorithm{
+		x509.RSA: {
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		},
+		x509.ECDSA: {
+			crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		},
+	}
+	if cert.PublicKeyAlgorithm == x509.Ed25519 {
+		return x509.PureEd25519, nil
+	}
+	if algorithm, ok := algorithms[cert.PublicKeyAlgorithm][hash]; ok {
+		return algorithm, nil
+	}
+	return 0, fmt.Errorf("unsupported TSA key type %s", cert.PublicKeyAlgorithm)
+}
@@ -0,0 +1,286 @@
+// Package tsatest runs an in-process RFC 3161 time-stamping authority so the
+// timestamp flow can be exercised offline. It signs whatever it is asked to
+// and must not be used as a real TSA.
+package tsatest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/intraceai/capture-node/internal/timestamp"
+)
+
+// oidSigningCertificateV2 names the ESS attribute binding the signer's
+// certificate to the signature (RFC 5035), which RFC 3161 requires.
+var oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+
+// essCertIDv2 omits the hash algorithm, which then defaults to SHA-256.
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// Policy is the TSA policy OID stamped into every token.
+var Policy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 3161, 1}
+
+type Server struct {
+	URL         string
+	Certificate *x509.Certificate
+	Root        *x509.Certificate
+	// Now returns the time put into tokens; it defaults to time.Now.
+	Now func() time.Time
+	// Tamper, when set, may alter the TSTInfo before it is signed, for
+	// exercising a client's checks.
+	Tamper func(*timestamp.TSTInfo)
+	// Reject, when set, refuses every request with this reason.
+	Reject string
+
+	key    *ecdsa.PrivateKey
+	server *httptest.Server
+
+	mu     sync.Mutex
+	serial int64
+}
+
+// NewServer starts a TSA whose certificate is issued by a fresh test root.
+func NewServer() (*Server, error) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tsatest root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 3161 requires a critical extended key usage of timeStamping only.
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 8}})
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "tsatest TSA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{2, 5, 29, 37}, Critical: true, Value: eku},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, root, &key.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{Certificate: cert, Root: root, Now: time.Now, key: key}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+	return s, nil
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Roots holds the root that issued the TSA certificate, for Client.Roots.
+func (s *Server) Roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Root)
+	return pool
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req timestamp.TimeStampReq
+	var resp interface{}
+	if _, err := asn1.Unmarshal(body, &req); err != nil {
+		resp = rejection("bad request")
+	} else if s.Reject != "" {
+		resp = rejection(s.Reject)
+	} else if !req.MessageImprint.HashAlgorithm.Algorithm.Equal(timestamp.OIDSHA256) {
+		resp = rejection("unsupported hash algorithm")
+	} else {
+		token, err := s.issue(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp = timestamp.TimeStampResp{
+			Status:         timestamp.PKIStatusInfo{Status: timestamp.StatusGranted},
+			TimeStampToken: asn1.RawValue{FullBytes: token},
+		}
+	}
+
+	out, err := asn1.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	w.Write(out)
+}
+
+// rejectedResp is a TimeStampResp without a token, whose status text is
+// encoded as the UTF8String PKIFreeText requires.
+type rejectedResp struct {
+	Status struct {
+		Status       int
+		StatusString []asn1.RawValue
+	}
+}
+
+func rejection(reason string) rejectedResp {
+	var resp rejectedResp
+	resp.Status.Status = 2
+	resp.Status.StatusString = []asn1.RawValue{{Tag: asn1.TagUTF8String, Bytes: []byte(reason)}}
+	return resp
+}
+
+func (s *Server) issue(req timestamp.TimeStampReq) ([]byte, error) {
+	s.mu.Lock()
+	s.serial++
+	serial := s.serial
+	s.mu.Unlock()
+
+	info := timestamp.TSTInfo{
+		Version:        1,
+		Policy:         Policy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   big.NewInt(serial),
+		GenTime:        s.Now().UTC().Truncate(time.Second),
+		Nonce:          req.Nonce,
+	}
+	if s.Tamper != nil {
+		s.Tamper(&info)
+	}
+	content, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	certHash := sha256.Sum256(s.Certificate.Raw)
+	contentDigest := crypto.SHA256.New()
+	contentDigest.Write(content)
+	signedAttrs, err := marshalAttributes(
+		attribute(timestamp.OIDContentType, timestamp.OIDTSTInfo),
+		attribute(timestamp.OIDMessageDigest, contentDigest.Sum(nil)),
+		attribute(oidSigningCertificateV2, signingCertificateV2{
+			Certs: []essCertIDv2{{CertHash: certHash[:]}},
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	attrsDigest := crypto.SHA256.New()
+	attrsDigest.Write(signedAttrs)
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, attrsDigest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	issuerAndSerial, err := asn1.Marshal(timestamp.IssuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: s.Certificate.RawIssuer},
+		SerialNumber: s.Certificate.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Signed attributes are sent with an implicit [0] tag.
+	implicitAttrs := append([]byte(nil), signedAttrs...)
+	implicitAttrs[0] = 0xa0
+
+	digestAlgorithm := pkix.AlgorithmIdentifier{Algorithm: timestamp.OIDSHA256}
+	signedData, err := asn1.Marshal(timestamp.SignedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlgorithm},
+		EncapContentInfo: timestamp.EncapsulatedContentInfo{
+			EContentType: timestamp.OIDTSTInfo,
+			EContent:     content,
+		},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      s.Certificate.Raw,
+		},
+		SignerInfos: []timestamp.SignerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: issuerAndSerial},
+			DigestAlgorithm:    digestAlgorithm,
+			SignedAttrs:        asn1.RawValue{FullBytes: implicitAttrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: timestamp.OIDECDSAWithSHA256},
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(timestamp.ContentInfo{
+		ContentType: timestamp.OIDSignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      signedData,
+		},
+	})
+}
+
+func attribute(oid asn1.ObjectIdentifier, value interface{}) timestamp.Attribute {
+	encoded, _ := asn1.Marshal(value)
+	return timestamp.Attribute{Type: oid, Values: []asn1.RawValue{{FullBytes: encoded}}}
+}
+
+// marshalAttributes encodes attributes as a DER SET OF, which is what the
+// signature covers.
+func marshalAttributes(attrs ...timestamp.Attribute) ([]byte, error) {
+	return asn1.MarshalWithParams(attrs, "set")
+}
@@ -0,0 +1,282 @@
+// Package tlsprobe records the identity of the server behind a URL: the
+// addresses its host resolves to and, for HTTPS, the negotiated TLS
+// parameters and certificate chain.
+package tlsprobe
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/intraceai/capture-node/pkg/shared"
+	"golang.org/x/net/proxy"
+)
+
+const defaultTimeout = 10 * time.Second
+
+type Prober struct {
+	Timeout time.Duration
+	// Roots verifies the presented chain. Nil uses the system pool.
+	Roots *x509.CertPool
+}
+
+func NewProber() *Prober {
+	return &Prober{Timeout: defaultTimeout}
+}
+
+// Probe connects to the host of rawURL the way the session's browser would,
+// through its proxy if it has one, and never to a denied address. Failures
+// after the URL is parsed are recorded in the result rather than returned,
+// so a capture still documents what could not be observed.
+func (p *Prober) Probe(ctx context.Context, rawURL string, policy shared.EgressPolicy) (*shared.ServerIdentity, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := parsed.Hostname()
+	if host == "" {
+		return nil, fmt.Errorf("URL has no host")
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "80"
+		if parsed.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	identity := &shared.ServerIdentity{
+		URL:      rawURL,
+		Host:     host,
+		Port:     port,
+		ProbedAt: time.Now().UTC(),
+	}
+	if policy.Proxy != "" {
+		identity.Proxy = redactProxy(policy.Proxy)
+	}
+
+	ips, err := resolve(ctx, host)
+	if err != nil {
+		identity.Error = err.Error()
+		return identity, nil
+	}
+	for _, ip := range ips {
+		identity.ResolvedIPs = append(identity.ResolvedIPs, ip.String())
+	}
+
+	denied, err := shared.ParseCIDRs(policy.DenyCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if shared.IPDenied(ip, denied) {
+			identity.Error = "host resolves to a denied address"
+			return identity, nil
+		}
+	}
+
+	if parsed.Scheme != "https" {
+		return identity, nil
+	}
+
+	conn, err := p.dial(ctx, policy.Proxy, ips, port, net.JoinHostPort(host, port))
+	if err != nil {
+		identity.Error = err.Error()
+		return identity, nil
+	}
+	defer conn.Close()
+
+	if policy.Proxy == "" {
+		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+			identity.ConnectedIP = addr.IP.String()
+		}
+	}
+
+	// Verification is done separately so an invalid chain is still recorded.
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"h2", "http/1.1"},
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		identity.Error = fmt.Sprintf("TLS handshake failed: %v", err)
+		return identity, nil
+	}
+
+	identity.TLS = p.describe(host, tlsConn.ConnectionState())
+	return identity, nil
+}
+
+func (p *Prober) describe(host string, state tls.ConnectionState) *shared.TLSConnection {
+	info := &shared.TLSConnection{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		ServerName:         host,
+	}
+
+	for _, cert := range state.PeerCertificates {
+		sum := sha256.Sum256(cert.Raw)
+		info.Certificates = append(info.Certificates, shared.Certificate{
+			Subject:           cert.Subject.String(),
+			Issuer:            cert.Issuer.String(),
+			SerialNumber:      cert.SerialNumber.Text(16),
+			NotBefore:         cert.NotBefore.UTC(),
+			NotAfter:          cert.NotAfter.UTC(),
+			DNSNames:          cert.DNSNames,
+			SHA256Fingerprint: hex.EncodeToString(sum[:]),
+			PEM:               string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		})
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		info.VerifyError = "server presented no certificates"
+		return info
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         p.Roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		info.VerifyError = err.Error()
+	} else {
+		info.Verified = true
+	}
+	return info
+}
+
+func (p *Prober) dial(ctx context.Context, proxyAddr string, ips []net.IP, port, target string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: p.Timeout}
+
+	if proxyAddr == "" {
+		// Dial the vetted addresses directly so a second lookup cannot
+		// land somewhere else.
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, fmt.Errorf("failed to connect: %w", lastErr)
+	}
+
+	proxyURL, err := url.Parse(proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(proxyURL, dialer)
+		if err != nil {
+			return nil, err
+		}
+		return socks.(proxy.ContextDialer).DialContext(ctx, "tcp", target)
+	case "http", "https":
+		return dialConnect(ctx, dialer, proxyURL, target)
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+}
+
+// dialConnect opens a tunnel to target through an HTTP proxy.
+func dialConnect(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, target string) (net.Conn, error) {
+	proxyPort := proxyURL.Port()
+	if proxyPort == "" {
+		proxyPort = "80"
+		if proxyURL.Scheme == "https" {
+			proxyPort = "443"
+		}
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(proxyURL.Hostname(), proxyPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy: %w", err)
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		req.SetBasicAuth(proxyURL.User.Username(), password)
+		req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+		req.Header.Del("Authorization")
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %w", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT returned %s", resp.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve host: %w", err)
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// redactProxy drops credentials before the proxy is written into evidence.
+func redactProxy(proxyAddr string) string {
+	parsed, err := url.Parse(proxyAddr)
+	if err != nil {
+		return ""
+	}
+	parsed.User = nil
+	return parsed.String()
+}
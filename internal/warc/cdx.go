@@ -0,0 +1,81 @@
+package warc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IndexEntry locates one record for replay tools. It is serialized as a
+// CDXJ line, the index format used inside WACZ files.
+type IndexEntry struct {
+	URL       string    `json:"url"`
+	Timestamp time.Time `json:"-"`
+	Mime      string    `json:"mime,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Digest    string    `json:"digest"`
+	Length    int64     `json:"length,string"`
+	Offset    int64     `json:"offset,string"`
+	Filename  string    `json:"filename"`
+}
+
+// CDXJ returns the entry as a single CDXJ line without a trailing newline.
+func (e IndexEntry) CDXJ() string {
+	var fields bytes.Buffer
+	enc := json.NewEncoder(&fields)
+	enc.SetEscapeHTML(false)
+	enc.Encode(e)
+	return SURT(e.URL) + " " + e.Timestamp.UTC().Format("20060102150405") + " " + strings.TrimSuffix(fields.String(), "\n")
+}
+
+// CDXJ renders entries as a sorted CDXJ index.
+func CDXJ(entries []IndexEntry) []byte {
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, e.CDXJ())
+	}
+	sort.Strings(lines)
+
+	var b bytes.Buffer
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// SURT returns the Sort-friendly URI Reordering Transform of rawURL, e.g.
+// "com,example)/path?q=1" for "https://www.example.com/path?q=1".
+func SURT(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(rawURL)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	labels := strings.Split(host, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	key := strings.Join(labels, ",")
+
+	port := u.Port()
+	if port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		key += ":" + port
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		sort.Strings(params)
+		path += "?" + strings.Join(params, "&")
+	}
+
+	return key + ")" + strings.ToLower(path)
+}
@@ -0,0 +1,309 @@
+// Package warc writes WARC 1.1 files with one gzip member per record, the
+// layout WACZ expects, and keeps a CDXJ index of what it wrote.
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intraceai/capture-node/pkg/shared"
+)
+
+const (
+	TypeWarcinfo = "warcinfo"
+	TypeResponse = "response"
+	TypeRequest  = "request"
+	TypeResource = "resource"
+	TypeMetadata = "metadata"
+)
+
+// Field is an extra WARC header.
+type Field struct {
+	Name  string
+	Value string
+}
+
+type Record struct {
+	Type        string
+	ID          string
+	Date        time.Time
+	TargetURI   string
+	ContentType string
+	Fields      []Field
+	Block       []byte
+}
+
+type Writer struct {
+	w        io.Writer
+	filename string
+	offset   int64
+	index    []IndexEntry
+}
+
+// NewWriter returns a Writer that writes records to w. filename is the
+// name the file will have inside the archive and is used in index entries.
+func NewWriter(w io.Writer, filename string) *Writer {
+	return &Writer{w: w, filename: filename}
+}
+
+func NewRecordID() string {
+	return "<urn:uuid:" + uuid.New().String() + ">"
+}
+
+// WriteRecord writes r as a single gzip member and returns its record ID,
+// offset and compressed length.
+func (w *Writer) WriteRecord(r Record) (id string, offset, length int64, err error) {
+	id = r.ID
+	if id == "" {
+		id = NewRecordID()
+	}
+	date := r.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var head bytes.Buffer
+	head.WriteString("WARC/1.1\r\n")
+	writeField(&head, "WARC-Type", r.Type)
+	writeField(&head, "WARC-Record-ID", id)
+	writeField(&head, "WARC-Date", date.UTC().Format(time.RFC3339Nano))
+	if r.TargetURI != "" {
+		writeField(&head, "WARC-Target-URI", r.TargetURI)
+	}
+	for _, f := range r.Fields {
+		writeField(&head, f.Name, f.Value)
+	}
+	if r.ContentType != "" {
+		writeField(&head, "Content-Type", r.ContentType)
+	}
+	writeField(&head, "WARC-Block-Digest", Digest(r.Block))
+	writeField(&head, "Content-Length", strconv.Itoa(len(r.Block)))
+	head.WriteString("\r\n")
+
+	var member bytes.Buffer
+	gz := gzip.NewWriter(&member)
+	gz.Write(head.Bytes())
+	gz.Write(r.Block)
+	gz.Write([]byte("\r\n\r\n"))
+	if err := gz.Close(); err != nil {
+		return "", 0, 0, err
+	}
+
+	n, err := w.w.Write(member.Bytes())
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to write WARC record: %w", err)
+	}
+	offset = w.offset
+	w.offset += int64(n)
+
+	return id, offset, int64(n), nil
+}
+
+// WriteWarcinfo writes the warcinfo record that opens a WARC file.
+func (w *Writer) WriteWarcinfo(date time.Time, fields []Field) error {
+	var block bytes.Buffer
+	for _, f := range fields {
+		writeField(&block, f.Name, f.Value)
+	}
+
+	_, _, _, err := w.WriteRecord(Record{
+		Type:        TypeWarcinfo,
+		Date:        date,
+		ContentType: "application/warc-fields",
+		Fields:      []Field{{Name: "WARC-Filename", Value: w.filename}},
+		Block:       block.Bytes(),
+	})
+	return err
+}
+
+// WriteExchange writes a response record and the request record that
+// produced it, linked through WARC-Concurrent-To.
+func (w *Writer) WriteExchange(ex shared.HTTPExchange) error {
+	body, err := base64.StdEncoding.DecodeString(ex.Body)
+	if err != nil {
+		return fmt.Errorf("invalid response body for %s: %w", ex.URL, err)
+	}
+	requestBody, err := base64.StdEncoding.DecodeString(ex.RequestBody)
+	if err != nil {
+		return fmt.Errorf("invalid request body for %s: %w", ex.URL, err)
+	}
+
+	payloadDigest := Digest(body)
+	responseFields := append(exchangeFields(ex), Field{Name: "WARC-Payload-Digest", Value: payloadDigest})
+
+	responseID, offset, length, err := w.WriteRecord(Record{
+		Type:        TypeResponse,
+		Date:        ex.StartedAt,
+		TargetURI:   ex.URL,
+		ContentType: "application/http;msgtype=response",
+		Fields:      responseFields,
+		Block:       responseBlock(ex, body),
+	})
+	if err != nil {
+		return err
+	}
+
+	w.index = append(w.index, IndexEntry{
+		URL:       ex.URL,
+		Timestamp: ex.StartedAt,
+		Mime:      mimeType(headerValue(ex.ResponseHeaders, "Content-Type")),
+		Status:    strconv.Itoa(ex.Status),
+		Digest:    payloadDigest,
+		Length:    length,
+		Offset:    offset,
+		Filename:  w.filename,
+	})
+
+	requestFields := append(exchangeFields(ex), Field{Name: "WARC-Concurrent-To", Value: responseID})
+	_, _, _, err = w.WriteRecord(Record{
+		Type:        TypeRequest,
+		Date:        ex.StartedAt,
+		TargetURI:   ex.URL,
+		ContentType: "application/http;msgtype=request",
+		Fields:      requestFields,
+		Block:       requestBlock(ex, requestBody),
+	})
+	return err
+}
+
+// exchangeFields returns a new slice of the headers shared by both records
+// of an exchange, so each record can append its own.
+func exchangeFields(ex shared.HTTPExchange) []Field {
+	var fields []Field
+	if ex.RemoteIP != "" {
+		fields = append(fields, Field{Name: "WARC-IP-Address", Value: ex.RemoteIP})
+	}
+	if ex.Protocol != "" && !strings.EqualFold(ex.Protocol, "http/1.1") {
+		fields = append(fields, Field{Name: "WARC-Protocol", Value: strings.ToLower(ex.Protocol)})
+	}
+	return fields
+}
+
+// WriteResource stores data as a resource record under targetURI.
+func (w *Writer) WriteResource(targetURI, contentType string, date time.Time, data []byte) error {
+	_, offset, length, err := w.WriteRecord(Record{
+		Type:        TypeResource,
+		Date:        date,
+		TargetURI:   targetURI,
+		ContentType: contentType,
+		Block:       data,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.index = append(w.index, IndexEntry{
+		URL:       targetURI,
+		Timestamp: date,
+		Mime:      mimeType(contentType),
+		Digest:    Digest(data),
+		Length:    length,
+		Offset:    offset,
+		Filename:  w.filename,
+	})
+	return nil
+}
+
+// Index returns the index entries for the response and resource records
+// written so far.
+func (w *Writer) Index() []IndexEntry {
+	return w.index
+}
+
+// Digest returns the WARC digest of data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// responseBlock serializes the response as HTTP/1.1. The browser hands over
+// decoded bodies, so transfer and content encodings are dropped and the
+// length is restated to match the payload actually stored.
+func responseBlock(ex shared.HTTPExchange, body []byte) []byte {
+	var b bytes.Buffer
+	statusText := ex.StatusText
+	if statusText == "" {
+		statusText = http.StatusText(ex.Status)
+	}
+	fmt.Fprintf(&b, "HTTP/1.1 %d %s\r\n", ex.Status, statusText)
+	for _, h := range ex.ResponseHeaders {
+		switch strings.ToLower(h.Name) {
+		case "content-encoding", "transfer-encoding", "content-length":
+			continue
+		}
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		writeField(&b, h.Name, h.Value)
+	}
+	writeField(&b, "Content-Length", strconv.Itoa(len(body)))
+	b.WriteString("\r\n")
+	b.Write(body)
+	return b.Bytes()
+}
+
+func requestBlock(ex shared.HTTPExchange, body []byte) []byte {
+	method := ex.Method
+	if method == "" {
+		method = "GET"
+	}
+	target := "/"
+	host := ""
+	if u, err := url.Parse(ex.URL); err == nil {
+		target = u.RequestURI()
+		host = u.Host
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", method, target)
+	hasHost := false
+	for _, h := range ex.RequestHeaders {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		if strings.EqualFold(h.Name, "host") {
+			hasHost = true
+		}
+		writeField(&b, h.Name, h.Value)
+	}
+	if !hasHost && host != "" {
+		writeField(&b, "Host", host)
+	}
+	b.WriteString("\r\n")
+	b.Write(body)
+	return b.Bytes()
+}
+
+func writeField(b *bytes.Buffer, name, value string) {
+	// Header values must not break the record framing.
+	value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+	b.WriteString(name)
+	b.WriteString(": ")
+	b.WriteString(value)
+	b.WriteString("\r\n")
+}
+
+func headerValue(headers []shared.HTTPHeader, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func mimeType(contentType string) string {
+	mime, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(strings.ToLower(mime))
+}
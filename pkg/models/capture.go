@@ -20,10 +20,74 @@ type Capture struct {
 	CreatedAt     time.Time       `json:"created_at"`
 }
 
+// CreateSessionRequest selects a browser profile by name, optionally applies
+// a device preset, and then overrides individual settings. An empty body uses
+// the default profile.
+type CreateSessionRequest struct {
+	Profile           string           `json:"profile"`
+	Device            string           `json:"device"`
+	Image             string           `json:"image"`
+	Viewport          *shared.Viewport `json:"viewport"`
+	DeviceScaleFactor float64          `json:"device_scale_factor"`
+	Locale            string           `json:"locale"`
+	Timezone          string           `json:"timezone"`
+	UserAgent         string           `json:"user_agent"`
+	MemoryMB          int              `json:"memory_mb"`
+	CPUs              float64          `json:"cpus"`
+	Proxy             string           `json:"proxy"`
+	// QueueTicket resumes a request that was queued with 202 Accepted.
+	QueueTicket string `json:"queue_ticket,omitempty"`
+}
+
+// QueuedSessionResponse tells a client its place in the session queue. It
+// retries the request with QueueTicket after RetryAfterSeconds.
+type QueuedSessionResponse struct {
+	QueueTicket       string `json:"queue_ticket"`
+	QueuePosition     int    `json:"queue_position"`
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+}
+
 type CreateSessionResponse struct {
-	SessionID string    `json:"session_id"`
-	StreamURL string    `json:"stream_url"`
-	ExpiresAt time.Time `json:"expires_at"`
+	SessionID    string                 `json:"session_id"`
+	StreamURL    string                 `json:"stream_url"`
+	ExpiresAt    time.Time              `json:"expires_at"`
+	MaxExpiresAt time.Time              `json:"max_expires_at"`
+	Profile      *shared.BrowserProfile `json:"profile,omitempty"`
+}
+
+type SessionInfo struct {
+	SessionID      string                 `json:"session_id"`
+	ClientID       string                 `json:"client_id,omitempty"`
+	Profile        *shared.BrowserProfile `json:"profile,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	ExpiresAt      time.Time              `json:"expires_at"`
+	MaxExpiresAt   time.Time              `json:"max_expires_at"`
+	LastActivityAt time.Time              `json:"last_activity_at"`
+	RequestedURL   string                 `json:"requested_url,omitempty"`
+	OpenedURLs     []string               `json:"opened_urls,omitempty"`
+	CurrentURL     string                 `json:"current_url,omitempty"`
+	Streaming      bool                   `json:"streaming"`
+	Viewers        int                    `json:"viewers"`
+	CaptureCount   int                    `json:"capture_count"`
+	Resources      *shared.ResourceUsage  `json:"resources,omitempty"`
+}
+
+type ListDevicesResponse struct {
+	Devices []shared.Device `json:"devices"`
+}
+
+type ListSessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+type ExtendSessionRequest struct {
+	DurationSeconds int `json:"duration_seconds"`
+}
+
+type ExtendSessionResponse struct {
+	SessionID    string    `json:"session_id"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxExpiresAt time.Time `json:"max_expires_at"`
 }
 
 type OpenURLRequest struct {
@@ -36,12 +100,16 @@ type CaptureResponse struct {
 }
 
 type CaptureMetadata struct {
-	CaptureID     string          `json:"capture_id"`
-	URL           string          `json:"url"`
-	FinalURL      string          `json:"final_url"`
-	CapturedAtUTC time.Time       `json:"captured_at_utc"`
-	Browser       shared.Browser  `json:"browser"`
-	Viewport      shared.Viewport `json:"viewport"`
-	Hashes        shared.Hashes   `json:"hashes"`
-	EventID       string          `json:"event_id"`
+	CaptureID     string                   `json:"capture_id"`
+	URL           string                   `json:"url"`
+	FinalURL      string                   `json:"final_url"`
+	Redirects     []shared.Redirect        `json:"redirects,omitempty"`
+	CapturedAtUTC time.Time                `json:"captured_at_utc"`
+	Browser       shared.Browser           `json:"browser"`
+	Viewport      shared.Viewport          `json:"viewport"`
+	Device        *shared.Device           `json:"device,omitempty"`
+	Hashes        shared.Hashes            `json:"hashes"`
+	Artifacts     []shared.Artifact        `json:"artifacts"`
+	EventID       string                   `json:"event_id"`
+	Timestamp     *shared.TrustedTimestamp `json:"timestamp,omitempty"`
 }
@@ -0,0 +1,211 @@
+package shared
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// RedactAllCookies in CaptureOptions.RedactCookies redacts every cookie.
+const RedactAllCookies = "*"
+
+// DocumentResponse is the HTTP response that delivered the main document.
+type DocumentResponse struct {
+	URL        string       `json:"url"`
+	Status     int          `json:"status"`
+	StatusText string       `json:"status_text,omitempty"`
+	Protocol   string       `json:"protocol,omitempty"`
+	Headers    []HTTPHeader `json:"headers"`
+	RemoteIP   string       `json:"remote_ip,omitempty"`
+}
+
+// Cookie is a browser cookie visible to the captured page. Redacted cookies
+// keep their attributes but lose their value.
+type Cookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Domain   string     `json:"domain"`
+	Path     string     `json:"path"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"http_only"`
+	Secure   bool       `json:"secure"`
+	SameSite string     `json:"same_site,omitempty"`
+	Redacted bool       `json:"redacted,omitempty"`
+}
+
+// RedactCookies blanks the values of the named cookies.
+func RedactCookies(cookies []Cookie, names []string) []Cookie {
+	redacted := make([]Cookie, len(cookies))
+	for i, cookie := range cookies {
+		if shouldRedact(cookie.Name, names) {
+			cookie.Value = ""
+			cookie.Redacted = true
+		}
+		redacted[i] = cookie
+	}
+	return redacted
+}
+
+// RedactSetCookie blanks the values of the named cookies in Set-Cookie
+// headers, keeping their attributes.
+func RedactSetCookie(headers []HTTPHeader, names []string) []HTTPHeader {
+	redacted := make([]HTTPHeader, len(headers))
+	for i, h := range headers {
+		if strings.EqualFold(h.Name, "Set-Cookie") {
+			var lines []string
+			// Browsers join repeated Set-Cookie headers with newlines.
+			for _, line := range strings.Split(h.Value, "\n") {
+				pair, attrs, _ := strings.Cut(line, ";")
+				name, _, _ := strings.Cut(pair, "=")
+				if shouldRedact(strings.TrimSpace(name), names) {
+					line = strings.TrimSpace(name) + "="
+					if attrs != "" {
+						line += ";" + attrs
+					}
+				}
+				lines = append(lines, line)
+			}
+			h.Value = strings.Join(lines, "\n")
+		}
+		redacted[i] = h
+	}
+	return redacted
+}
+
+// RedactCookieHeader blanks the values of the named cookies in Cookie
+// request headers.
+func RedactCookieHeader(headers []HTTPHeader, names []string) []HTTPHeader {
+	redacted := make([]HTTPHeader, len(headers))
+	for i, h := range headers {
+		if strings.EqualFold(h.Name, "Cookie") {
+			pairs := strings.Split(h.Value, ";")
+			for j, pair := range pairs {
+				name, _, _ := strings.Cut(pair, "=")
+				if name = strings.TrimSpace(name); shouldRedact(name, names) {
+					pairs[j] = name + "="
+					if j > 0 {
+						pairs[j] = " " + pairs[j]
+					}
+				}
+			}
+			h.Value = strings.Join(pairs, ";")
+		}
+		redacted[i] = h
+	}
+	return redacted
+}
+
+// RedactExchanges blanks the named cookies in the Cookie and Set-Cookie
+// headers of recorded HTTP exchanges.
+func RedactExchanges(exchanges []HTTPExchange, names []string) []HTTPExchange {
+	redacted := make([]HTTPExchange, len(exchanges))
+	for i, ex := range exchanges {
+		ex.RequestHeaders = RedactCookieHeader(ex.RequestHeaders, names)
+		ex.ResponseHeaders = RedactSetCookie(ex.ResponseHeaders, names)
+		redacted[i] = ex
+	}
+	return redacted
+}
+
+// RedactHAR blanks the named cookies in a HAR log: the Cookie and
+// Set-Cookie headers of every entry and its parsed cookie lists. Fields the
+// redaction does not touch are kept as recorded.
+func RedactHAR(data []byte, names []string) ([]byte, error) {
+	if len(names) == 0 {
+		return data, nil
+	}
+	var har map[string]json.RawMessage
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, err
+	}
+	rawLog, ok := har["log"]
+	if !ok {
+		return data, nil
+	}
+	var log map[string]json.RawMessage
+	if err := json.Unmarshal(rawLog, &log); err != nil {
+		return nil, err
+	}
+	var entries []map[string]json.RawMessage
+	if raw, ok := log["entries"]; ok {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range entries {
+		for _, side := range []string{"request", "response"} {
+			raw, ok := entry[side]
+			if !ok {
+				continue
+			}
+			redacted, err := redactHARMessage(raw, names)
+			if err != nil {
+				return nil, err
+			}
+			entry[side] = redacted
+		}
+	}
+
+	var err error
+	if log["entries"], err = json.Marshal(entries); err != nil {
+		return nil, err
+	}
+	if har["log"], err = json.Marshal(log); err != nil {
+		return nil, err
+	}
+	return json.Marshal(har)
+}
+
+func redactHARMessage(raw json.RawMessage, names []string) (json.RawMessage, error) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, err
+	}
+
+	if rawHeaders, ok := msg["headers"]; ok {
+		var headers []map[string]any
+		if err := json.Unmarshal(rawHeaders, &headers); err != nil {
+			return nil, err
+		}
+		for _, header := range headers {
+			name, _ := header["name"].(string)
+			value, _ := header["value"].(string)
+			h := []HTTPHeader{{Name: name, Value: value}}
+			header["value"] = RedactSetCookie(RedactCookieHeader(h, names), names)[0].Value
+		}
+		data, err := json.Marshal(headers)
+		if err != nil {
+			return nil, err
+		}
+		msg["headers"] = data
+	}
+
+	if rawCookies, ok := msg["cookies"]; ok {
+		var cookies []map[string]any
+		if err := json.Unmarshal(rawCookies, &cookies); err != nil {
+			return nil, err
+		}
+		for _, cookie := range cookies {
+			if name, _ := cookie["name"].(string); shouldRedact(name, names) {
+				cookie["value"] = ""
+			}
+		}
+		data, err := json.Marshal(cookies)
+		if err != nil {
+			return nil, err
+		}
+		msg["cookies"] = data
+	}
+
+	return json.Marshal(msg)
+}
+
+func shouldRedact(name string, names []string) bool {
+	for _, n := range names {
+		if n == RedactAllCookies || n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactCookieHeader(t *testing.T) {
+	tests := []struct {
+		value string
+		names []string
+		want  string
+	}{
+		{"session=abc; theme=dark", []string{"session"}, "session=; theme=dark"},
+		{"theme=dark; session=abc", []string{"session"}, "theme=dark; session="},
+		{"session=abc; theme=dark", []string{RedactAllCookies}, "session=; theme="},
+		{"session=abc", nil, "session=abc"},
+	}
+	for _, tt := range tests {
+		got := RedactCookieHeader([]HTTPHeader{{Name: "cookie", Value: tt.value}}, tt.names)
+		if got[0].Value != tt.want {
+			t.Errorf("RedactCookieHeader(%q, %v) = %q, want %q", tt.value, tt.names, got[0].Value, tt.want)
+		}
+	}
+}
+
+func TestRedactExchanges(t *testing.T) {
+	exchanges := []HTTPExchange{{
+		RequestHeaders:  []HTTPHeader{{Name: "Cookie", Value: "session=abc"}},
+		ResponseHeaders: []HTTPHeader{{Name: "Set-Cookie", Value: "session=def; Path=/; HttpOnly"}},
+	}}
+	got := RedactExchanges(exchanges, []string{"session"})
+	if v := got[0].RequestHeaders[0].Value; v != "session=" {
+		t.Errorf("Cookie = %q", v)
+	}
+	if v := got[0].ResponseHeaders[0].Value; v != "session=; Path=/; HttpOnly" {
+		t.Errorf("Set-Cookie = %q", v)
+	}
+	if v := exchanges[0].RequestHeaders[0].Value; v != "session=abc" {
+		t.Errorf("input modified: Cookie = %q", v)
+	}
+}
+
+func TestRedactHAR(t *testing.T) {
+	har := `{"log":{"version":"1.2","entries":[{
+		"request":{"method":"GET","headers":[{"name":"Cookie","value":"session=abc; theme=dark"}],"cookies":[{"name":"session","value":"abc"}]},
+		"response":{"status":200,"headers":[{"name":"Set-Cookie","value":"session=def; Secure"}],"cookies":[{"name":"session","value":"def","secure":true}]}
+	}]}}`
+
+	got, err := RedactHAR([]byte(har), []string{"session"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"abc", "def"} {
+		if strings.Contains(string(got), secret) {
+			t.Errorf("redacted HAR still contains %q: %s", secret, got)
+		}
+	}
+	for _, kept := range []string{"theme=dark", `"version":"1.2"`, `"secure":true`, "session=; Secure"} {
+		if !strings.Contains(string(got), kept) {
+			t.Errorf("redacted HAR lost %s: %s", kept, got)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package shared
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"math"
 	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
 )
 
 func SHA256Hex(data []byte) string {
@@ -12,21 +18,209 @@ func SHA256Hex(data []byte) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// CanonicalJSON encodes v with the JSON Canonicalization Scheme of RFC 8785,
+// so verifiers in other languages reproduce the same bytes.
 func CanonicalJSON(v interface{}) ([]byte, error) {
 	data, err := json.Marshal(v)
 	if err != nil {
 		return nil, err
 	}
 
+	// Numbers are kept as written so they can be re-serialized from their
+	// IEEE 754 value rather than from Go's formatting.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var intermediate interface{}
+	if err := decoder.Decode(&intermediate); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := marshalCanonical(&buf, intermediate); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalCanonical(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		// Properties are ordered by their UTF-16 code units, which differs
+		// from byte order for characters outside the BMP.
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := marshalCanonical(buf, val[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := marshalCanonical(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case string:
+		writeCanonicalString(buf, val)
+
+	case json.Number:
+		f, err := strconv.ParseFloat(string(val), 64)
+		if err != nil {
+			return fmt.Errorf("number %s is not representable: %w", val, err)
+		}
+		number, err := canonicalNumber(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(number)
+
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+
+	case nil:
+		buf.WriteString("null")
+
+	default:
+		return fmt.Errorf("unexpected JSON value %T", v)
+	}
+	return nil
+}
+
+func lessUTF16(a, b string) bool {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeCanonicalString escapes only what RFC 8785, section 3.2.2.2 requires:
+// quotation mark, reverse solidus and control characters.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hexDigits = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[r>>4])
+				buf.WriteByte(hexDigits[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// canonicalNumber formats f like ECMAScript's Number.prototype.toString,
+// as RFC 8785, section 3.2.2.3 requires.
+func canonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("number %v is not valid JSON", f)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	// Shortest round-tripping digits d1.d2...dk and exponent, so that the
+	// value is digits * 10^(n-k).
+	mantissa, exp, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	e, _ := strconv.Atoi(exp)
+	k := len(digits)
+	n := e + 1
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+
+	expSign := "+"
+	if n-1 < 0 {
+		expSign = "-"
+	}
+	exponent := "e" + expSign + strconv.Itoa(abs(n-1))
+	if k == 1 {
+		return sign + digits + exponent, nil
+	}
+	return sign + digits[:1] + "." + digits[1:] + exponent, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// legacyCanonicalJSON is the encoding manifests were hashed with before
+// they recorded a canonicalization: keys in byte order and Go's own number
+// and string formatting. It is kept to verify those manifests.
+func legacyCanonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
 	var intermediate interface{}
 	if err := json.Unmarshal(data, &intermediate); err != nil {
 		return nil, err
 	}
 
-	return marshalCanonical(intermediate)
+	return marshalLegacy(intermediate)
 }
 
-func marshalCanonical(v interface{}) ([]byte, error) {
+func marshalLegacy(v interface{}) ([]byte, error) {
 	switch val := v.(type) {
 	case map[string]interface{}:
 		keys := make([]string, 0, len(val))
@@ -43,7 +237,7 @@ func marshalCanonical(v interface{}) ([]byte, error) {
 			keyBytes, _ := json.Marshal(k)
 			result = append(result, keyBytes...)
 			result = append(result, ':')
-			valBytes, err := marshalCanonical(val[k])
+			valBytes, err := marshalLegacy(val[k])
 			if err != nil {
 				return nil, err
 			}
@@ -58,7 +252,7 @@ func marshalCanonical(v interface{}) ([]byte, error) {
 			if i > 0 {
 				result = append(result, ',')
 			}
-			itemBytes, err := marshalCanonical(item)
+			itemBytes, err := marshalLegacy(item)
 			if err != nil {
 				return nil, err
 			}
@@ -0,0 +1,102 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// DefaultDeniedCIDRs covers loopback, private, link-local, shared,
+// multicast, reserved, broadcast and unspecified address space, plus the
+// NAT64 prefix that maps onto IPv4, which browser sessions must never reach.
+var DefaultDeniedCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"255.255.255.255/32",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// EgressPolicy is the outbound network policy applied to a session.
+type EgressPolicy struct {
+	Proxy     string   `json:"proxy,omitempty"`
+	DenyCIDRs []string `json:"deny_cidrs,omitempty"`
+}
+
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ValidateEgress resolves the URL's host and rejects it if any address falls
+// inside a denied range. Checking every address prevents a hostname with
+// mixed public and private records from slipping through.
+func ValidateEgress(ctx context.Context, rawURL string, denied []*net.IPNet) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := parsed.Hostname()
+	if host == "" {
+		return &ValidationError{Field: "url", Message: "host is required"}
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return &ValidationError{Field: "url", Message: "host could not be resolved"}
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	for _, ip := range ips {
+		if IPDenied(ip, denied) {
+			return &ValidationError{Field: "url", Message: "host resolves to a denied address"}
+		}
+	}
+	return nil
+}
+
+// nat64Prefix is the well-known NAT64 prefix (RFC 6052), whose last 32 bits
+// are the IPv4 address a translator connects to.
+var nat64Prefix = &net.IPNet{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)}
+
+func IPDenied(ip net.IP, denied []*net.IPNet) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	} else if nat64Prefix.Contains(ip) && IPDenied(ip[12:16], denied) {
+		return true
+	}
+	for _, ipNet := range denied {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ManifestVersion is the manifest schema written by this version.
+const ManifestVersion = 2
+
+// CanonicalizationJCS is RFC 8785 canonical JSON, as produced by
+// CanonicalJSON.
+const CanonicalizationJCS = "jcs-rfc8785"
+
+// Artifact file names within captures/<id>/.
+const (
+	DOMFile       = "dom.html"
+	PDFFile       = "page.pdf"
+	MHTMLFile     = "page.mhtml"
+	WARCFile      = "archive.warc.gz"
+	WARCIndexFile = "archive.cdxj"
+	HARFile       = "network.har"
+	TLSFile       = "tls.json"
+	ResponseFile  = "response.json"
+	CookiesFile   = "cookies.json"
+	ConsoleFile   = "console.json"
+	ManifestFile  = "manifest.json"
+	EventFile     = "event.json"
+)
+
+const (
+	MediaTypeHTML  = "text/html; charset=utf-8"
+	MediaTypePDF   = "application/pdf"
+	MediaTypeMHTML = "multipart/related"
+	MediaTypeJSON  = "application/json"
+	MediaTypeGzip  = "application/gzip"
+	MediaTypeCDXJ  = "application/cdxj"
+)
+
+// Artifact is one file produced by a capture.
+type Artifact struct {
+	Name      string `json:"name"`
+	MediaType string `json:"media_type"`
+	// Size is unknown (zero) for artifacts of upgraded v1 manifests.
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Path   string `json:"path"`
+}
+
+// Artifact returns the artifact with the given file name.
+func (m *Manifest) Artifact(name string) (Artifact, bool) {
+	for _, a := range m.Artifacts {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// ArtifactPath is the storage path of a capture's artifact.
+func ArtifactPath(captureID, name string) string {
+	return fmt.Sprintf("captures/%s/%s", captureID, name)
+}
+
+func ScreenshotFile(format string) string {
+	return "screenshot." + imageExtension(format)
+}
+
+func FullPageFile(format string) string {
+	return "fullpage." + imageExtension(format)
+}
+
+func ImageMediaType(format string) string {
+	if format == ImageFormatJPEG {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+func imageExtension(format string) string {
+	if format == ImageFormatJPEG {
+		return "jpg"
+	}
+	return "png"
+}
+
+// HashesFromArtifacts fills the fixed hash fields the event log accepts. It
+// exists only for that compatibility: the manifest's artifact list is the
+// record of every artifact, and artifacts without a field here are left out.
+func HashesFromArtifacts(manifestHash string, artifacts []Artifact) Hashes {
+	hashes := Hashes{ManifestSHA256: manifestHash}
+	for _, a := range artifacts {
+		switch {
+		case strings.HasPrefix(a.Name, "screenshot."):
+			hashes.ScreenshotSHA256 = a.SHA256
+		case strings.HasPrefix(a.Name, "fullpage."):
+			hashes.FullPageSHA256 = a.SHA256
+		case a.Name == DOMFile:
+			hashes.DOMSHA256 = a.SHA256
+		case a.Name == PDFFile:
+			hashes.PDFSHA256 = a.SHA256
+		case a.Name == MHTMLFile:
+			hashes.MHTMLSHA256 = a.SHA256
+		case a.Name == WARCFile:
+			hashes.WARCSHA256 = a.SHA256
+		case a.Name == HARFile:
+			hashes.HARSHA256 = a.SHA256
+		case a.Name == TLSFile:
+			hashes.TLSSHA256 = a.SHA256
+		case a.Name == ResponseFile:
+			hashes.ResponseSHA256 = a.SHA256
+		case a.Name == CookiesFile:
+			hashes.CookiesSHA256 = a.SHA256
+		case a.Name == ConsoleFile:
+			hashes.ConsoleSHA256 = a.SHA256
+		}
+	}
+	return hashes
+}
+
+// CanonicalManifest returns the bytes a stored manifest's hash and signature
+// cover, using the canonicalization the manifest records.
+func CanonicalManifest(data []byte) ([]byte, error) {
+	var header struct {
+		Canonicalization string `json:"canonicalization"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	switch header.Canonicalization {
+	case CanonicalizationJCS:
+		return CanonicalJSON(json.RawMessage(data))
+	case "":
+		return legacyCanonicalJSON(json.RawMessage(data))
+	}
+	return nil, fmt.Errorf("unsupported canonicalization %q", header.Canonicalization)
+}
+
+// manifestV1 is the unversioned manifest layout with one hash field per
+// artifact kind.
+type manifestV1 struct {
+	CaptureID     string    `json:"capture_id"`
+	URL           string    `json:"url"`
+	FinalURL      string    `json:"final_url"`
+	CapturedAtUTC time.Time `json:"captured_at_utc"`
+	Browser       Browser   `json:"browser"`
+	Viewport      Viewport  `json:"viewport"`
+	Hashes        struct {
+		ScreenshotSHA256 string `json:"screenshot_sha256"`
+		DOMSHA256        string `json:"dom_sha256"`
+		FullPageSHA256   string `json:"full_page_screenshot_sha256"`
+		PDFSHA256        string `json:"pdf_sha256"`
+		MHTMLSHA256      string `json:"mhtml_sha256"`
+		WARCSHA256       string `json:"warc_sha256"`
+		HARSHA256        string `json:"har_sha256"`
+		TLSSHA256        string `json:"tls_sha256"`
+		ResponseSHA256   string `json:"response_sha256"`
+		CookiesSHA256    string `json:"cookies_sha256"`
+		ConsoleSHA256    string `json:"console_sha256"`
+	} `json:"hashes"`
+	Visibility     string          `json:"visibility"`
+	Profile        *BrowserProfile `json:"profile,omitempty"`
+	Device         *Device         `json:"device,omitempty"`
+	FullPage       *FullPage       `json:"full_page,omitempty"`
+	Redirects      []Redirect      `json:"redirects,omitempty"`
+	CaptureOptions *CaptureOptions `json:"capture_options,omitempty"`
+}
+
+// ParseManifest decodes a stored manifest of any supported version into the
+// current layout. The result of upgrading a v1 manifest does not hash to the
+// original manifest hash; verify against the stored bytes instead.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	switch header.Version {
+	case 0, 1:
+		var v1 manifestV1
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return nil, err
+		}
+		return v1.upgrade(), nil
+	case ManifestVersion:
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+	return nil, fmt.Errorf("unsupported manifest version %d", header.Version)
+}
+
+func (v1 *manifestV1) upgrade() *Manifest {
+	m := &Manifest{
+		Version:        1,
+		CaptureID:      v1.CaptureID,
+		URL:            v1.URL,
+		FinalURL:       v1.FinalURL,
+		CapturedAtUTC:  v1.CapturedAtUTC,
+		Browser:        v1.Browser,
+		Viewport:       v1.Viewport,
+		Visibility:     v1.Visibility,
+		Profile:        v1.Profile,
+		Device:         v1.Device,
+		FullPage:       v1.FullPage,
+		Redirects:      v1.Redirects,
+		CaptureOptions: v1.CaptureOptions,
+	}
+
+	format := ImageFormatPNG
+	if v1.CaptureOptions != nil && v1.CaptureOptions.ImageFormat != "" {
+		format = v1.CaptureOptions.ImageFormat
+	}
+
+	h := v1.Hashes
+	for _, a := range []struct {
+		name, mediaType, hash string
+	}{
+		{ScreenshotFile(format), ImageMediaType(format), h.ScreenshotSHA256},
+		{DOMFile, MediaTypeHTML, h.DOMSHA256},
+		{FullPageFile(format), ImageMediaType(format), h.FullPageSHA256},
+		{PDFFile, MediaTypePDF, h.PDFSHA256},
+		{MHTMLFile, MediaTypeMHTML, h.MHTMLSHA256},
+		{WARCFile, MediaTypeGzip, h.WARCSHA256},
+		{HARFile, MediaTypeJSON, h.HARSHA256},
+		{TLSFile, MediaTypeJSON, h.TLSSHA256},
+		{ResponseFile, MediaTypeJSON, h.ResponseSHA256},
+		{CookiesFile, MediaTypeJSON, h.CookiesSHA256},
+		{ConsoleFile, MediaTypeJSON, h.ConsoleSHA256},
+	} {
+		if a.hash == "" {
+			continue
+		}
+		m.Artifacts = append(m.Artifacts, Artifact{
+			Name:      a.name,
+			MediaType: a.mediaType,
+			SHA256:    a.hash,
+			Path:      ArtifactPath(v1.CaptureID, a.name),
+		})
+	}
+	return m
+}
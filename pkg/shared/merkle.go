@@ -0,0 +1,193 @@
+package shared
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// MerkleAlgorithm names the tree construction recorded in
+// Manifest.MerkleAlgorithm: an RFC 6962 Merkle tree over the artifacts
+// sorted by name, where each leaf is SHA-256(0x00 || name || 0x00 ||
+// artifact SHA-256) and each interior node is SHA-256(0x01 || left || right).
+const MerkleAlgorithm = "rfc6962-sha256"
+
+// MerkleProof shows that an artifact is a leaf of the tree with Root.
+// Path lists sibling hashes from the leaf upwards, as in RFC 6962.
+type MerkleProof struct {
+	Algorithm string   `json:"algorithm"`
+	Artifact  string   `json:"artifact"`
+	SHA256    string   `json:"sha256"`
+	LeafIndex int      `json:"leaf_index"`
+	TreeSize  int      `json:"tree_size"`
+	LeafHash  string   `json:"leaf_hash"`
+	Path      []string `json:"path"`
+	Root      string   `json:"root"`
+}
+
+// MerkleRoot returns the hex root over the artifacts.
+func MerkleRoot(artifacts []Artifact) (string, error) {
+	leaves, _, err := merkleLeaves(artifacts)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(merkleHash(leaves)), nil
+}
+
+// NewMerkleProof builds the inclusion proof for the named artifact.
+func NewMerkleProof(artifacts []Artifact, name string) (*MerkleProof, error) {
+	leaves, sorted, err := merkleLeaves(artifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+	for i, a := range sorted {
+		if a.Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("artifact %q not found", name)
+	}
+
+	var path []string
+	for _, node := range merklePath(index, leaves) {
+		path = append(path, hex.EncodeToString(node))
+	}
+
+	return &MerkleProof{
+		Algorithm: MerkleAlgorithm,
+		Artifact:  name,
+		SHA256:    sorted[index].SHA256,
+		LeafIndex: index,
+		TreeSize:  len(leaves),
+		LeafHash:  hex.EncodeToString(leaves[index]),
+		Path:      path,
+		Root:      hex.EncodeToString(merkleHash(leaves)),
+	}, nil
+}
+
+// VerifyMerkleProof recomputes the root from the proof's artifact and path
+// (RFC 9162, section 2.1.3.2) and compares it with Root.
+func VerifyMerkleProof(p *MerkleProof) error {
+	if p.LeafIndex < 0 || p.LeafIndex >= p.TreeSize {
+		return fmt.Errorf("leaf index out of range")
+	}
+
+	leaf, err := merkleLeaf(p.Artifact, p.SHA256)
+	if err != nil {
+		return err
+	}
+
+	fn, sn := p.LeafIndex, p.TreeSize-1
+	r := leaf
+	for _, encoded := range p.Path {
+		sibling, err := hex.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("invalid path hash: %w", err)
+		}
+		if sn == 0 {
+			return fmt.Errorf("proof path is too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			r = merkleNode(sibling, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNode(r, sibling)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	root, err := hex.DecodeString(p.Root)
+	if err != nil {
+		return fmt.Errorf("invalid root: %w", err)
+	}
+	if sn != 0 || !bytes.Equal(r, root) {
+		return fmt.Errorf("proof does not match root")
+	}
+	return nil
+}
+
+func merkleLeaves(artifacts []Artifact) ([][]byte, []Artifact, error) {
+	if len(artifacts) == 0 {
+		return nil, nil, fmt.Errorf("no artifacts")
+	}
+
+	sorted := append([]Artifact(nil), artifacts...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	leaves := make([][]byte, len(sorted))
+	for i, a := range sorted {
+		if i > 0 && a.Name == sorted[i-1].Name {
+			return nil, nil, fmt.Errorf("duplicate artifact %q", a.Name)
+		}
+		leaf, err := merkleLeaf(a.Name, a.SHA256)
+		if err != nil {
+			return nil, nil, err
+		}
+		leaves[i] = leaf
+	}
+	return leaves, sorted, nil
+}
+
+func merkleLeaf(name, sha256Hex string) ([]byte, error) {
+	digest, err := hex.DecodeString(sha256Hex)
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 for artifact %q", name)
+	}
+
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write([]byte(name))
+	h.Write([]byte{0x00})
+	h.Write(digest)
+	return h.Sum(nil), nil
+}
+
+func merkleNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleHash is MTH from RFC 6962 applied to precomputed leaf hashes.
+func merkleHash(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := splitPoint(len(leaves))
+	return merkleNode(merkleHash(leaves[:k]), merkleHash(leaves[k:]))
+}
+
+// merklePath is PATH from RFC 6962.
+func merklePath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := splitPoint(len(leaves))
+	if m < k {
+		return append(merklePath(m, leaves[:k]), merkleHash(leaves[k:]))
+	}
+	return append(merklePath(m-k, leaves[k:]), merkleHash(leaves[:k]))
+}
+
+// splitPoint returns the largest power of two smaller than n.
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
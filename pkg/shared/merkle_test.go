@@ -0,0 +1,143 @@
+package shared
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func testArtifacts(n int) []Artifact {
+	artifacts := make([]Artifact, n)
+	for i := range artifacts {
+		name := fmt.Sprintf("artifact-%d", i)
+		artifacts[i] = Artifact{Name: name, SHA256: SHA256Hex([]byte(name))}
+	}
+	return artifacts
+}
+
+func TestMerkleRoot(t *testing.T) {
+	artifacts := testArtifacts(3)
+	leaves := make([][]byte, len(artifacts))
+	for i, a := range artifacts {
+		leaf, err := merkleLeaf(a.Name, a.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaves[i] = leaf
+	}
+	want := hex.EncodeToString(merkleNode(merkleNode(leaves[0], leaves[1]), leaves[2]))
+
+	// The root does not depend on the order artifacts are listed in.
+	reversed := []Artifact{artifacts[2], artifacts[1], artifacts[0]}
+	for _, list := range [][]Artifact{artifacts, reversed} {
+		got, err := MerkleRoot(list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got root %s, want %s", got, want)
+		}
+	}
+
+	if _, err := MerkleRoot(append(artifacts, artifacts[0])); err == nil {
+		t.Error("expected duplicate artifacts to fail")
+	}
+	if _, err := MerkleRoot(nil); err == nil {
+		t.Error("expected an empty tree to fail")
+	}
+}
+
+func TestMerkleProof(t *testing.T) {
+	for size := 1; size <= 7; size++ {
+		artifacts := testArtifacts(size)
+		root, err := MerkleRoot(artifacts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, a := range artifacts {
+			t.Run(fmt.Sprintf("size %d/%s", size, a.Name), func(t *testing.T) {
+				proof, err := NewMerkleProof(artifacts, a.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if proof.Root != root || proof.TreeSize != size {
+					t.Fatalf("proof for root %s size %d, want %s size %d", proof.Root, proof.TreeSize, root, size)
+				}
+				if err := VerifyMerkleProof(proof); err != nil {
+					t.Fatalf("valid proof rejected: %v", err)
+				}
+
+				for name, tamper := range proofTampers(size) {
+					tampered := *proof
+					tampered.Path = append([]string(nil), proof.Path...)
+					if !tamper(&tampered) {
+						continue
+					}
+					if err := VerifyMerkleProof(&tampered); err == nil {
+						t.Errorf("%s: tampered proof verified", name)
+					}
+				}
+			})
+		}
+	}
+}
+
+// proofTampers returns changes that must invalidate a proof in a tree of
+// size leaves. Each reports false when it does not apply to the proof.
+func proofTampers(size int) map[string]func(*MerkleProof) bool {
+	flip := func(encoded string) string {
+		b, _ := hex.DecodeString(encoded)
+		b[0] ^= 0x01
+		return hex.EncodeToString(b)
+	}
+	return map[string]func(*MerkleProof) bool{
+		"sibling": func(p *MerkleProof) bool {
+			if len(p.Path) == 0 {
+				return false
+			}
+			p.Path[len(p.Path)/2] = flip(p.Path[len(p.Path)/2])
+			return true
+		},
+		"next index": func(p *MerkleProof) bool {
+			if size == 1 {
+				return false
+			}
+			p.LeafIndex = (p.LeafIndex + 1) % size
+			return true
+		},
+		"index out of range": func(p *MerkleProof) bool {
+			p.LeafIndex = size
+			return true
+		},
+		"root": func(p *MerkleProof) bool {
+			p.Root = flip(p.Root)
+			return true
+		},
+		"artifact hash": func(p *MerkleProof) bool {
+			p.SHA256 = flip(p.SHA256)
+			return true
+		},
+		"artifact name": func(p *MerkleProof) bool {
+			p.Artifact += "x"
+			return true
+		},
+		"extra sibling": func(p *MerkleProof) bool {
+			p.Path = append(p.Path, p.Root)
+			return true
+		},
+		"missing sibling": func(p *MerkleProof) bool {
+			if len(p.Path) == 0 {
+				return false
+			}
+			p.Path = p.Path[:len(p.Path)-1]
+			return true
+		},
+	}
+}
+
+func TestNewMerkleProofUnknownArtifact(t *testing.T) {
+	if _, err := NewMerkleProof(testArtifacts(3), "missing"); err == nil {
+		t.Error("expected an unknown artifact to fail")
+	}
+}
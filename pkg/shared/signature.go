@@ -0,0 +1,41 @@
+package shared
+
+import "time"
+
+// SignatureFile holds the node's detached signature over the canonical
+// manifest bytes.
+const SignatureFile = "manifest.sig"
+
+// ManifestSignature is a detached signature over CanonicalJSON(manifest).
+type ManifestSignature struct {
+	Algorithm      string    `json:"algorithm"`
+	KeyID          string    `json:"key_id"`
+	ManifestSHA256 string    `json:"manifest_sha256"`
+	Signature      string    `json:"signature"`
+	SignedAt       time.Time `json:"signed_at"`
+}
+
+// NodeKey is a capture node's published verification key.
+type NodeKey struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+	PEM       string `json:"public_key_pem"`
+	// RetiredAt is set once the node signs with a newer key. Retired keys
+	// stay published so earlier captures still verify.
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+type NodeKeys struct {
+	Keys []NodeKey `json:"keys"`
+}
+
+// Find returns the key with the given ID.
+func (k NodeKeys) Find(keyID string) (NodeKey, bool) {
+	for _, key := range k.Keys {
+		if key.KeyID == keyID {
+			return key, true
+		}
+	}
+	return NodeKey{}, false
+}
@@ -0,0 +1,19 @@
+package shared
+
+import "time"
+
+// TimestampFile holds the DER RFC 3161 timestamp token over the manifest hash.
+const TimestampFile = "timestamp.tsr"
+
+const MediaTypeTimestampToken = "application/timestamp-token"
+
+// TrustedTimestamp summarizes an RFC 3161 token for display. The token file
+// is authoritative.
+type TrustedTimestamp struct {
+	GenTime       time.Time `json:"gen_time"`
+	SerialNumber  string    `json:"serial_number"`
+	Policy        string    `json:"policy"`
+	HashAlgorithm string    `json:"hash_algorithm"`
+	HashedMessage string    `json:"hashed_message"`
+	Signer        string    `json:"signer,omitempty"`
+}
@@ -1,16 +1,34 @@
 package shared
 
-import "time"
+import (
+	"net/url"
+	"strings"
+	"time"
+)
 
 type Hashes struct {
 	ManifestSHA256   string `json:"manifest_sha256"`
 	ScreenshotSHA256 string `json:"screenshot_sha256"`
 	DOMSHA256        string `json:"dom_sha256"`
+	FullPageSHA256   string `json:"full_page_screenshot_sha256,omitempty"`
+	PDFSHA256        string `json:"pdf_sha256,omitempty"`
+	MHTMLSHA256      string `json:"mhtml_sha256,omitempty"`
+	WARCSHA256       string `json:"warc_sha256,omitempty"`
+	HARSHA256        string `json:"har_sha256,omitempty"`
+	TLSSHA256        string `json:"tls_sha256,omitempty"`
+	ResponseSHA256   string `json:"response_sha256,omitempty"`
+	CookiesSHA256    string `json:"cookies_sha256,omitempty"`
+	ConsoleSHA256    string `json:"console_sha256,omitempty"`
+	MerkleRoot       string `json:"merkle_root,omitempty"`
 }
 
+// Browser identifies the browser that made a capture. Version is the
+// product version the browser reports, and UserAgent is the user agent
+// the page saw, which a profile may override.
 type Browser struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	UserAgent string `json:"user_agent,omitempty"`
 }
 
 type Viewport struct {
@@ -18,18 +36,79 @@ type Viewport struct {
 	Height int `json:"height"`
 }
 
+// BrowserProfile describes how a session's browser was configured.
+type BrowserProfile struct {
+	Name              string    `json:"name,omitempty"`
+	Image             string    `json:"image"`
+	Device            string    `json:"device,omitempty"`
+	Viewport          *Viewport `json:"viewport,omitempty"`
+	DeviceScaleFactor float64   `json:"device_scale_factor,omitempty"`
+	Mobile            bool      `json:"mobile,omitempty"`
+	Touch             bool      `json:"touch,omitempty"`
+	Locale            string    `json:"locale,omitempty"`
+	Timezone          string    `json:"timezone,omitempty"`
+	UserAgent         string    `json:"user_agent,omitempty"`
+	MemoryMB          int       `json:"memory_mb"`
+	CPUs              float64   `json:"cpus"`
+}
+
+// EmulatedDevice returns the device the profile emulates, or nil for a
+// plain desktop browser.
+func (p *BrowserProfile) EmulatedDevice() *Device {
+	if p == nil || p.Device == "" {
+		return nil
+	}
+
+	device := &Device{
+		Name:              p.Device,
+		DeviceScaleFactor: p.DeviceScaleFactor,
+		Mobile:            p.Mobile,
+		Touch:             p.Touch,
+		UserAgent:         p.UserAgent,
+	}
+	if p.Viewport != nil {
+		device.Viewport = *p.Viewport
+	}
+	return device
+}
+
+// Device is an emulated phone or tablet.
+type Device struct {
+	Name              string   `json:"name"`
+	Viewport          Viewport `json:"viewport"`
+	DeviceScaleFactor float64  `json:"device_scale_factor"`
+	Mobile            bool     `json:"mobile"`
+	Touch             bool     `json:"touch"`
+	UserAgent         string   `json:"user_agent"`
+}
+
+// Manifest describes a capture and the artifacts it produced. Manifests
+// without a version are v1 manifests; see ParseManifest.
 type Manifest struct {
-	CaptureID     string    `json:"capture_id"`
-	URL           string    `json:"url"`
-	FinalURL      string    `json:"final_url"`
-	CapturedAtUTC time.Time `json:"captured_at_utc"`
-	Browser       Browser   `json:"browser"`
-	Viewport      Viewport  `json:"viewport"`
-	Hashes        struct {
-		ScreenshotSHA256 string `json:"screenshot_sha256"`
-		DOMSHA256        string `json:"dom_sha256"`
-	} `json:"hashes"`
-	Visibility string `json:"visibility"`
+	Version int `json:"version"`
+	// Canonicalization names the encoding the manifest hash covers. It is
+	// empty for manifests hashed before CanonicalizationJCS.
+	Canonicalization string `json:"canonicalization,omitempty"`
+
+	CaptureID     string     `json:"capture_id"`
+	URL           string     `json:"url"`
+	FinalURL      string     `json:"final_url"`
+	CapturedAtUTC time.Time  `json:"captured_at_utc"`
+	Browser       Browser    `json:"browser"`
+	Viewport      Viewport   `json:"viewport"`
+	Artifacts     []Artifact `json:"artifacts"`
+	// MerkleRoot commits to all artifacts, built as MerkleAlgorithm names.
+	// Manifests with a root but no algorithm used MerkleAlgorithm.
+	MerkleRoot      string `json:"merkle_root,omitempty"`
+	MerkleAlgorithm string `json:"merkle_algorithm,omitempty"`
+
+	Visibility string          `json:"visibility"`
+	Profile    *BrowserProfile `json:"profile,omitempty"`
+	Device     *Device         `json:"device,omitempty"`
+	FullPage   *FullPage       `json:"full_page,omitempty"`
+	Redirects  []Redirect      `json:"redirects,omitempty"`
+
+	CaptureOptions *CaptureOptions `json:"capture_options,omitempty"`
 }
 
 type CaptureEvent struct {
@@ -52,19 +131,257 @@ type CreateEventRequest struct {
 	Hashes        Hashes    `json:"hashes"`
 }
 
+const (
+	ArtifactViewport = "viewport"
+	ArtifactFullPage = "full_page"
+	ArtifactPDF      = "pdf"
+	ArtifactMHTML    = "mhtml"
+	ArtifactHAR      = "har"
+	ArtifactWARC     = "warc"
+	ArtifactCookies  = "cookies"
+
+	ImageFormatPNG  = "png"
+	ImageFormatJPEG = "jpeg"
+)
+
+// CaptureOptions controls what a capture produces. It is recorded in the
+// manifest so each capture describes how it was taken.
+type CaptureOptions struct {
+	// Artifacts lists what to produce. The viewport screenshot is always
+	// taken; it is the primary evidence.
+	Artifacts         []string     `json:"artifacts"`
+	Wait              *WaitOptions `json:"wait,omitempty"`
+	ClipSelector      string       `json:"clip_selector,omitempty"`
+	ImageFormat       string       `json:"image_format"`
+	ImageQuality      int          `json:"image_quality,omitempty"`
+	MaxFullPageHeight int          `json:"max_full_page_height,omitempty"`
+	// RedactCookies names cookies whose values are removed from every
+	// artifact that records them: the cookies and response artifacts and
+	// the Cookie and Set-Cookie headers in the HAR and WARC; "*" redacts
+	// all.
+	RedactCookies []string `json:"redact_cookies,omitempty"`
+	Note          string   `json:"note,omitempty"`
+}
+
+// WaitOptions are conditions the browser waits for before capturing.
+type WaitOptions struct {
+	NetworkIdle bool   `json:"network_idle,omitempty"`
+	Selector    string `json:"selector,omitempty"`
+	DelayMS     int    `json:"delay_ms,omitempty"`
+	TimeoutMS   int    `json:"timeout_ms,omitempty"`
+}
+
+func (o *CaptureOptions) Has(artifact string) bool {
+	for _, a := range o.Artifacts {
+		if a == artifact {
+			return true
+		}
+	}
+	return false
+}
+
+type BrowserCaptureRequest struct {
+	FullPage          bool         `json:"full_page,omitempty"`
+	MaxFullPageHeight int          `json:"max_full_page_height,omitempty"`
+	Wait              *WaitOptions `json:"wait,omitempty"`
+	ClipSelector      string       `json:"clip_selector,omitempty"`
+	ImageFormat       string       `json:"image_format,omitempty"`
+	ImageQuality      int          `json:"image_quality,omitempty"`
+	PDF               bool         `json:"pdf,omitempty"`
+	MHTML             bool         `json:"mhtml,omitempty"`
+	Exchanges         bool         `json:"exchanges,omitempty"`
+	// HAR asks for the network log the agent recorded since the page was
+	// last opened.
+	HAR     bool `json:"har,omitempty"`
+	Cookies bool `json:"cookies,omitempty"`
+}
+
 type BrowserCaptureResponse struct {
 	Screenshot string   `json:"screenshot"`
 	DOM        string   `json:"dom"`
 	FinalURL   string   `json:"final_url"`
 	Viewport   Viewport `json:"viewport"`
 	UserAgent  string   `json:"user_agent"`
+	// Product is what CDP Browser.getVersion reports, e.g.
+	// "HeadlessChrome/120.0.6099.109". A user agent override does not
+	// change it.
+	Product string `json:"product,omitempty"`
+
+	FullPageScreenshot string `json:"full_page_screenshot,omitempty"`
+	FullPageHeight     int    `json:"full_page_height,omitempty"`
+	FullPageTruncated  bool   `json:"full_page_truncated,omitempty"`
+	PDF                string `json:"pdf,omitempty"`
+	MHTML              string `json:"mhtml,omitempty"`
+	HAR                string `json:"har,omitempty"`
+
+	// Redirects is the redirect chain that led to the current document.
+	Redirects []Redirect `json:"redirects,omitempty"`
+
+	Response *DocumentResponse `json:"response,omitempty"`
+	Cookies  []Cookie          `json:"cookies,omitempty"`
+
+	// Console holds the console messages and uncaught exceptions the
+	// session's pages produced so far.
+	Console []ConsoleEntry `json:"console,omitempty"`
+
+	Exchanges []HTTPExchange `json:"exchanges,omitempty"`
+}
+
+type HTTPHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HTTPExchange is one request/response pair observed by the browser while
+// loading the page. Bodies are base64 encoded and already content-decoded.
+type HTTPExchange struct {
+	URL             string       `json:"url"`
+	Method          string       `json:"method"`
+	RequestHeaders  []HTTPHeader `json:"request_headers"`
+	RequestBody     string       `json:"request_body,omitempty"`
+	Status          int          `json:"status"`
+	StatusText      string       `json:"status_text,omitempty"`
+	Protocol        string       `json:"protocol,omitempty"`
+	ResponseHeaders []HTTPHeader `json:"response_headers"`
+	Body            string       `json:"body"`
+	RemoteIP        string       `json:"remote_ip,omitempty"`
+	StartedAt       time.Time    `json:"started_at"`
+}
+
+// ServerIdentity records which server answered for a URL at capture time.
+type ServerIdentity struct {
+	URL         string         `json:"url"`
+	Host        string         `json:"host"`
+	Port        string         `json:"port"`
+	ResolvedIPs []string       `json:"resolved_ips"`
+	ConnectedIP string         `json:"connected_ip,omitempty"`
+	Proxy       string         `json:"proxy,omitempty"`
+	TLS         *TLSConnection `json:"tls,omitempty"`
+	ProbedAt    time.Time      `json:"probed_at"`
+	Error       string         `json:"error,omitempty"`
+}
+
+type TLSConnection struct {
+	Version            string        `json:"version"`
+	CipherSuite        string        `json:"cipher_suite"`
+	NegotiatedProtocol string        `json:"negotiated_protocol,omitempty"`
+	ServerName         string        `json:"server_name"`
+	Certificates       []Certificate `json:"certificates"`
+	Verified           bool          `json:"verified"`
+	VerifyError        string        `json:"verify_error,omitempty"`
+}
+
+// Certificate is one certificate of the presented chain, leaf first.
+type Certificate struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serial_number"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	DNSNames          []string  `json:"dns_names,omitempty"`
+	SHA256Fingerprint string    `json:"sha256_fingerprint"`
+	PEM               string    `json:"pem"`
+}
+
+const (
+	ConsoleLog       = "log"
+	ConsoleInfo      = "info"
+	ConsoleWarning   = "warning"
+	ConsoleError     = "error"
+	ConsoleDebug     = "debug"
+	ConsoleException = "exception"
+)
+
+// ConsoleEntry is a console message or, with Type ConsoleException, an
+// uncaught JavaScript exception.
+type ConsoleEntry struct {
+	Type       string    `json:"type"`
+	Text       string    `json:"text"`
+	URL        string    `json:"url,omitempty"`
+	Line       int       `json:"line,omitempty"`
+	Column     int       `json:"column,omitempty"`
+	StackTrace string    `json:"stack_trace,omitempty"`
+	PageURL    string    `json:"page_url,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Redirect is one hop of a redirect chain: URL answered with Status and
+// sent the browser on to Location.
+type Redirect struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+}
+
+// PageURL is the URL a captured page was reached from: requested when the
+// redirect chain starts at it or the page is still at it, otherwise the
+// first redirect hop or the final URL, since the browser navigated away
+// from what was requested.
+func PageURL(requested, final string, redirects []Redirect) string {
+	if requested != "" {
+		if sameURL(requested, final) || (len(redirects) > 0 && sameURL(requested, redirects[0].URL)) {
+			return requested
+		}
+	}
+	if len(redirects) > 0 {
+		return redirects[0].URL
+	}
+	return final
+}
+
+// sameURL compares URLs the way the browser reports them, which adds the
+// root path and lowercases the scheme and host.
+func sameURL(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	for _, u := range []*url.URL{ua, ub} {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+	return ua.String() == ub.String()
+}
+
+// FullPage describes a full-page screenshot recorded next to the viewport
+// screenshot.
+type FullPage struct {
+	Height    int  `json:"height"`
+	Truncated bool `json:"truncated"`
 }
 
 type Session struct {
-	SessionID   string    `json:"session_id"`
-	ContainerID string    `json:"-"` // internal only
-	ContainerIP string    `json:"-"` // internal only
-	APIPort     int       `json:"-"` // container internal port
-	CreatedAt   time.Time `json:"created_at"`
-	ExpiresAt   time.Time `json:"expires_at"`
+	SessionID   string          `json:"session_id"`
+	ClientID    string          `json:"client_id,omitempty"`
+	Profile     *BrowserProfile `json:"profile,omitempty"`
+	Egress      *EgressPolicy   `json:"egress,omitempty"`
+	ContainerID string          `json:"-"` // internal only
+	ContainerIP string          `json:"-"` // internal only
+	APIPort     int             `json:"-"` // container internal port
+	CreatedAt   time.Time       `json:"created_at"`
+	ExpiresAt   time.Time       `json:"expires_at"`
+
+	MaxExpiresAt   time.Time `json:"max_expires_at"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+
+	RequestedURL string   `json:"requested_url,omitempty"`
+	OpenedURLs   []string `json:"opened_urls,omitempty"`
+	CurrentURL   string   `json:"current_url,omitempty"`
+	Streaming    bool     `json:"streaming"`
+	CaptureCount int      `json:"capture_count"`
+}
+
+type ResourceUsage struct {
+	CPUPercent       float64 `json:"cpu_percent"`
+	MemoryBytes      uint64  `json:"memory_bytes"`
+	MemoryLimitBytes uint64  `json:"memory_limit_bytes"`
+	NetworkRxBytes   uint64  `json:"network_rx_bytes"`
+	NetworkTxBytes   uint64  `json:"network_tx_bytes"`
 }
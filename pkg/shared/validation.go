@@ -2,6 +2,7 @@ package shared
 
 import (
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -22,6 +23,94 @@ func ValidateURL(rawURL string) error {
 	return nil
 }
 
+const (
+	maxSelectorLength = 1024
+	maxNoteLength     = 4096
+	maxWaitDelayMS    = 10000
+	maxWaitTimeoutMS  = 15000
+	maxRedactCookies  = 256
+)
+
+// supportedArtifacts are the artifact kinds a capture can produce.
+var supportedArtifacts = map[string]bool{
+	ArtifactViewport: true,
+	ArtifactFullPage: true,
+	ArtifactPDF:      true,
+	ArtifactMHTML:    true,
+	ArtifactWARC:     true,
+	ArtifactHAR:      true,
+	ArtifactCookies:  true,
+}
+
+// NormalizeCaptureOptions validates the options and fills in defaults: the
+// viewport artifact is always present and artifacts are de-duplicated.
+func NormalizeCaptureOptions(opts *CaptureOptions) error {
+	artifacts := []string{ArtifactViewport}
+	seen := map[string]bool{ArtifactViewport: true}
+	for _, artifact := range opts.Artifacts {
+		if !supportedArtifacts[artifact] {
+			return &ValidationError{Field: "artifacts", Message: "unsupported artifact " + strconv.Quote(artifact)}
+		}
+		if !seen[artifact] {
+			seen[artifact] = true
+			artifacts = append(artifacts, artifact)
+		}
+	}
+	opts.Artifacts = artifacts
+
+	switch opts.ImageFormat {
+	case "":
+		opts.ImageFormat = ImageFormatPNG
+	case ImageFormatPNG, ImageFormatJPEG:
+	default:
+		return &ValidationError{Field: "image_format", Message: "must be png or jpeg"}
+	}
+
+	if opts.ImageQuality != 0 {
+		if opts.ImageFormat != ImageFormatJPEG {
+			return &ValidationError{Field: "image_quality", Message: "only applies to jpeg"}
+		}
+		if opts.ImageQuality < 1 || opts.ImageQuality > 100 {
+			return &ValidationError{Field: "image_quality", Message: "must be between 1 and 100"}
+		}
+	}
+
+	if opts.MaxFullPageHeight < 0 {
+		return &ValidationError{Field: "max_full_page_height", Message: "must not be negative"}
+	}
+
+	if len(opts.ClipSelector) > maxSelectorLength {
+		return &ValidationError{Field: "clip_selector", Message: "is too long"}
+	}
+
+	if len(opts.RedactCookies) > maxRedactCookies {
+		return &ValidationError{Field: "redact_cookies", Message: "has too many entries"}
+	}
+	for _, name := range opts.RedactCookies {
+		if name == "" {
+			return &ValidationError{Field: "redact_cookies", Message: "must not contain empty names"}
+		}
+	}
+
+	if len(opts.Note) > maxNoteLength {
+		return &ValidationError{Field: "note", Message: "is too long"}
+	}
+
+	if w := opts.Wait; w != nil {
+		if len(w.Selector) > maxSelectorLength {
+			return &ValidationError{Field: "wait.selector", Message: "is too long"}
+		}
+		if w.DelayMS < 0 || w.DelayMS > maxWaitDelayMS {
+			return &ValidationError{Field: "wait.delay_ms", Message: "must be between 0 and " + strconv.Itoa(maxWaitDelayMS)}
+		}
+		if w.TimeoutMS < 0 || w.TimeoutMS > maxWaitTimeoutMS {
+			return &ValidationError{Field: "wait.timeout_ms", Message: "must be between 0 and " + strconv.Itoa(maxWaitTimeoutMS)}
+		}
+	}
+
+	return nil
+}
+
 func SanitizeURL(rawURL string) string {
 	rawURL = strings.TrimSpace(rawURL)
 	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {